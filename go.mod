@@ -2,4 +2,8 @@ module github.com/whoisnian/glb
 
 go 1.26.0
 
-require golang.org/x/sys v0.41.0
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.48.0
+	golang.org/x/sys v0.41.0
+)
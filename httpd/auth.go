@@ -0,0 +1,300 @@
+package httpd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Principal is the authenticated identity of request, set by auth middlewares.
+type Principal struct {
+	Scheme string // "Basic", "Bearer" or "HMAC"
+	Name   string // username, token owner or key ID
+}
+
+// Principal returns the authenticated identity set by auth middlewares, or nil if not authenticated.
+func (store *Store) Principal() *Principal {
+	principal, _ := GetAs[*Principal](store, KeyPrincipal)
+	return principal
+}
+
+func replyUnauthorized(store *Store, challenge string) {
+	store.W.Header().Set("WWW-Authenticate", challenge)
+	http.Error(store.W, "401 unauthorized", http.StatusUnauthorized)
+}
+
+// constantTimeEqual compares hashes of a and b, so that the length of secret is not leaked.
+func constantTimeEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// BasicAuthOptions configures the BasicAuth middleware.
+// Credentials are checked by Users, Htpasswd and Validate in order, and any of them can be nil.
+type BasicAuthOptions struct {
+	Realm    string            // default "Restricted"
+	Users    map[string]string // username to plain password, compared in constant time
+	Htpasswd *Htpasswd
+	Validate func(username, password string) bool
+}
+
+// BasicAuth returns a middleware that authenticates requests with HTTP Basic authentication (RFC 7617).
+// Unauthenticated requests are replied 401 with 'WWW-Authenticate' challenge.
+func BasicAuth(opts BasicAuthOptions) HandlerFunc {
+	if opts.Realm == "" {
+		opts.Realm = "Restricted"
+	}
+	challenge := `Basic realm=` + strconv.Quote(opts.Realm) + `, charset="UTF-8"`
+	check := func(username, password string) bool {
+		if expected, ok := opts.Users[username]; ok && constantTimeEqual(password, expected) {
+			return true
+		}
+		if opts.Htpasswd != nil && opts.Htpasswd.Validate(username, password) {
+			return true
+		}
+		return opts.Validate != nil && opts.Validate(username, password)
+	}
+
+	return func(store *Store) {
+		username, password, ok := store.R.BasicAuth()
+		if !ok || !check(username, password) {
+			replyUnauthorized(store, challenge)
+			return
+		}
+		store.Set(KeyPrincipal, &Principal{Scheme: "Basic", Name: username})
+		store.Next()
+	}
+}
+
+// Htpasswd validates passwords with bcrypt hashes from htpasswd file, which can be created by `htpasswd -B`.
+type Htpasswd struct {
+	name  string
+	users atomic.Pointer[map[string][]byte]
+}
+
+// LoadHtpasswd loads htpasswd file with bcrypt hashes, other hash formats are rejected.
+func LoadHtpasswd(name string) (*Htpasswd, error) {
+	h := &Htpasswd{name: name}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Reload reads the htpasswd file again and replaces users atomically. The old users are kept on error.
+func (h *Htpasswd) Reload() error {
+	f, err := os.Open(h.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" || !strings.HasPrefix(hash, "$2") {
+			return errors.New("httpd: invalid or unsupported htpasswd entry at " + h.name + ":" + strconv.Itoa(lineNum))
+		}
+		users[username] = []byte(hash)
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	h.users.Store(&users)
+	return nil
+}
+
+// Validate reports whether password matches the bcrypt hash of username.
+func (h *Htpasswd) Validate(username, password string) bool {
+	hash, ok := (*h.users.Load())[username]
+	return ok && bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// BearerAuthOptions configures the BearerAuth middleware.
+// Tokens are checked by Tokens and Validate in order, and any of them can be nil.
+type BearerAuthOptions struct {
+	Realm    string            // default "Restricted"
+	Tokens   map[string]string // static token to principal name, compared in constant time
+	Validate func(store *Store, token string) (name string, ok bool)
+}
+
+// BearerAuth returns a middleware that authenticates requests with 'Authorization: Bearer <token>' (RFC 6750).
+// Unauthenticated requests are replied 401 with 'WWW-Authenticate' challenge.
+func BearerAuth(opts BearerAuthOptions) HandlerFunc {
+	if opts.Realm == "" {
+		opts.Realm = "Restricted"
+	}
+	challenge := `Bearer realm=` + strconv.Quote(opts.Realm)
+	check := func(store *Store, token string) (string, bool) {
+		var matched string
+		var ok bool
+		for expected, name := range opts.Tokens { // compare all tokens in constant time
+			if constantTimeEqual(token, expected) {
+				matched, ok = name, true
+			}
+		}
+		if ok || opts.Validate == nil {
+			return matched, ok
+		}
+		return opts.Validate(store, token)
+	}
+
+	return func(store *Store) {
+		auth := store.R.Header.Get("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			replyUnauthorized(store, challenge)
+			return
+		}
+		name, ok := check(store, strings.TrimSpace(auth[7:]))
+		if !ok {
+			replyUnauthorized(store, challenge+`, error="invalid_token"`)
+			return
+		}
+		store.Set(KeyPrincipal, &Principal{Scheme: "Bearer", Name: name})
+		store.Next()
+	}
+}
+
+// Headers of HMAC request signing.
+const (
+	HMACKeyIDHeader     = "X-HMAC-Key-ID"
+	HMACTimestampHeader = "X-HMAC-Timestamp"
+	HMACNonceHeader     = "X-HMAC-Nonce"
+	HMACSignatureHeader = "X-HMAC-Signature"
+)
+
+// HMACAuthOptions configures the HMACAuth middleware.
+type HMACAuthOptions struct {
+	Keys   func(keyID string) (key []byte, ok bool)
+	Window time.Duration // maximum clock skew of timestamp, and how long nonces are remembered, default 5 minutes
+}
+
+// SignRequest signs request with HMAC-SHA256 for HMACAuth middleware. The body is read and restored.
+// The signature is hex encoded HMAC of the following lines joined by '\n':
+//   - request method
+//   - request URI, e.g. "/path?query"
+//   - unix timestamp in seconds
+//   - random nonce
+//   - hex encoded SHA256 of request body
+func SignRequest(r *http.Request, keyID string, key []byte) error {
+	var nonce [16]byte
+	rand.Read(nonce[:]) // crypto/rand.Read() never returns an error
+	body, err := readAndRestoreBody(r)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(HMACKeyIDHeader, keyID)
+	r.Header.Set(HMACTimestampHeader, timestamp)
+	r.Header.Set(HMACNonceHeader, hex.EncodeToString(nonce[:]))
+	r.Header.Set(HMACSignatureHeader, hex.EncodeToString(hmacSignature(key, r.Method, r.URL.RequestURI(), timestamp, hex.EncodeToString(nonce[:]), body)))
+	return nil
+}
+
+func hmacSignature(key []byte, method, uri, timestamp, nonce string, body []byte) []byte {
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, method+"\n"+uri+"\n"+timestamp+"\n"+nonce+"\n"+hex.EncodeToString(bodySum[:]))
+	return mac.Sum(nil)
+}
+
+func readAndRestoreBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// HMACAuth returns a middleware that verifies requests signed by SignRequest. Requests with timestamp outside
+// Window, or nonce that has been used within Window, are rejected as replay. Use MaxBodySize before HMACAuth
+// to limit the request body, which is read into memory for verification.
+func HMACAuth(opts HMACAuthOptions) HandlerFunc {
+	if opts.Keys == nil {
+		panic("httpd: HMACAuth requires Keys")
+	}
+	if opts.Window <= 0 {
+		opts.Window = 5 * time.Minute
+	}
+	nonces := &nonceCache{seen: make(map[string]time.Time)}
+
+	return func(store *Store) {
+		h := store.R.Header
+		keyID, timestamp, nonce := h.Get(HMACKeyIDHeader), h.Get(HMACTimestampHeader), h.Get(HMACNonceHeader)
+		signature, err := hex.DecodeString(h.Get(HMACSignatureHeader))
+		key, ok := opts.Keys(keyID)
+		if err != nil || !ok || nonce == "" {
+			replyUnauthorized(store, "HMAC-SHA256")
+			return
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		now := time.Now()
+		if err != nil || now.Sub(time.Unix(ts, 0)).Abs() > opts.Window {
+			replyUnauthorized(store, "HMAC-SHA256")
+			return
+		}
+		body, err := readAndRestoreBody(store.R)
+		if err != nil {
+			http.Error(store.W, "400 bad request", http.StatusBadRequest)
+			return
+		}
+		expected := hmacSignature(key, store.R.Method, store.R.URL.RequestURI(), timestamp, nonce, body)
+		if !hmac.Equal(signature, expected) || !nonces.add(keyID+":"+nonce, now, opts.Window) {
+			replyUnauthorized(store, "HMAC-SHA256")
+			return
+		}
+		store.Set(KeyPrincipal, &Principal{Scheme: "HMAC", Name: keyID})
+		store.Next()
+	}
+}
+
+// nonceCache remembers nonces until expiry, and removes expired ones every window.
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// add returns false if nonce has been seen and not expired.
+func (c *nonceCache) add(nonce string, now time.Time, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) >= window {
+		for k, expiry := range c.seen {
+			if now.After(expiry) {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	if expiry, ok := c.seen[nonce]; ok && !now.After(expiry) {
+		return false
+	}
+	c.seen[nonce] = now.Add(2 * window) // timestamp is accepted within [now-window, now+window]
+	return true
+}
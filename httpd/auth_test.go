@@ -0,0 +1,167 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuthMux(auth httpd.HandlerFunc) *httpd.Mux {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(auth)
+	handler := func(s *httpd.Store) { s.Respond200([]byte(s.Principal().Scheme + ":" + s.Principal().Name)) }
+	mux.Handle("/", http.MethodGet, handler)
+	mux.Handle("/", http.MethodPost, handler)
+	return mux
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pass2"), bcrypt.MinCost)
+	name := filepath.Join(t.TempDir(), ".htpasswd")
+	os.WriteFile(name, []byte("# comment\nbob:"+string(hash)+"\n"), 0644)
+	htpasswd, err := httpd.LoadHtpasswd(name)
+	if err != nil {
+		t.Fatalf("LoadHtpasswd() got error %v", err)
+	}
+
+	mux := newAuthMux(httpd.BasicAuth(httpd.BasicAuthOptions{
+		Realm:    "test",
+		Users:    map[string]string{"alice": "pass1"},
+		Htpasswd: htpasswd,
+		Validate: func(username, password string) bool { return username == "carol" && password == "pass3" },
+	}))
+	tests := []struct {
+		username, password string
+		code               int
+		body               string
+	}{
+		{"alice", "pass1", 200, "Basic:alice"},
+		{"alice", "pass2", 401, "401 unauthorized\n"},
+		{"bob", "pass2", 200, "Basic:bob"},
+		{"bob", "pass1", 401, "401 unauthorized\n"},
+		{"carol", "pass3", 200, "Basic:carol"},
+		{"", "", 401, "401 unauthorized\n"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.username != "" {
+			r.SetBasicAuth(tt.username, tt.password)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Fatalf("BasicAuth(%s, %s) return %d %q, want %d %q", tt.username, tt.password, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if want := `Basic realm="test", charset="UTF-8"`; tt.code == 401 && w.Header().Get("WWW-Authenticate") != want {
+			t.Fatalf("BasicAuth(%s, %s) return challenge %q, want %q", tt.username, tt.password, w.Header().Get("WWW-Authenticate"), want)
+		}
+	}
+
+	os.WriteFile(name, []byte("dave:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"), 0644)
+	if err = htpasswd.Reload(); err == nil {
+		t.Fatalf("Reload() with unsupported hash should fail")
+	}
+	if !htpasswd.Validate("bob", "pass2") {
+		t.Fatalf("Validate() after failed Reload() should keep old users")
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	mux := newAuthMux(httpd.BearerAuth(httpd.BearerAuthOptions{
+		Tokens: map[string]string{"static-token": "ci"},
+		Validate: func(s *httpd.Store, token string) (string, bool) {
+			name, ok := strings.CutPrefix(token, "dynamic-")
+			return name, ok
+		},
+	}))
+	tests := []struct {
+		auth      string
+		code      int
+		body      string
+		challenge string
+	}{
+		{"Bearer static-token", 200, "Bearer:ci", ""},
+		{"bearer dynamic-bot", 200, "Bearer:bot", ""},
+		{"Bearer wrong", 401, "401 unauthorized\n", `Bearer realm="Restricted", error="invalid_token"`},
+		{"Basic abc", 401, "401 unauthorized\n", `Bearer realm="Restricted"`},
+		{"", 401, "401 unauthorized\n", `Bearer realm="Restricted"`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", tt.auth)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body || w.Header().Get("WWW-Authenticate") != tt.challenge {
+			t.Fatalf("BearerAuth(%q) return %d %q %q, want %d %q %q", tt.auth, w.Code, w.Body.String(), w.Header().Get("WWW-Authenticate"), tt.code, tt.body, tt.challenge)
+		}
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	keys := map[string][]byte{"k1": []byte("secret")}
+	mux := newAuthMux(httpd.HMACAuth(httpd.HMACAuthOptions{
+		Keys:   func(keyID string) ([]byte, bool) { key, ok := keys[keyID]; return key, ok },
+		Window: time.Minute,
+	}))
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code == 200 && w.Body.String() != "HMAC:k1" {
+			t.Fatalf("HMACAuth return body %q, want %q", w.Body.String(), "HMAC:k1")
+		}
+		return w.Code
+	}
+	newSigned := func(keyID string, key []byte, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/?a=1", strings.NewReader(body))
+		if err := httpd.SignRequest(r, keyID, key); err != nil {
+			t.Fatalf("SignRequest() got error %v", err)
+		}
+		return r
+	}
+
+	r := newSigned("k1", []byte("secret"), "payload")
+	if code := serve(r); code != 200 {
+		t.Fatalf("signed request return %d, want 200", code)
+	}
+	if code := serve(newSigned("k1", []byte("secret"), "payload")); code != 200 {
+		t.Fatalf("signed request with new nonce return %d, want 200", code)
+	}
+
+	replay := newSigned("k1", []byte("secret"), "payload")
+	serve(replay.Clone(replay.Context()))
+	replay.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")).Body
+	if code := serve(replay); code != 401 {
+		t.Fatalf("replayed request return %d, want 401", code)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+	}{
+		{"unknown key", func(r *http.Request) { *r = *newSigned("k2", []byte("secret"), "payload") }},
+		{"wrong key", func(r *http.Request) { *r = *newSigned("k1", []byte("wrong"), "payload") }},
+		{"tampered body", func(r *http.Request) {
+			r.Body = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("tampered")).Body
+		}},
+		{"tampered query", func(r *http.Request) { r.URL.RawQuery = "a=2"; r.RequestURI = "" }},
+		{"expired timestamp", func(r *http.Request) {
+			r.Header.Set(httpd.HMACTimestampHeader, strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10))
+		}},
+		{"missing signature", func(r *http.Request) { r.Header.Del(httpd.HMACSignatureHeader) }},
+	}
+	for _, tt := range tests {
+		r := newSigned("k1", []byte("secret"), "payload")
+		tt.modify(r)
+		if code := serve(r); code != 401 {
+			t.Fatalf("%s return %d, want 401", tt.name, code)
+		}
+	}
+}
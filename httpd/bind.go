@@ -0,0 +1,459 @@
+package httpd
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes a field that fails to bind or validate.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// BindError is returned by Store.Bind() for invalid request, and can be rendered as 400 response with per-field messages.
+// Field of decoding error for the whole request body is "body".
+type BindError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *BindError) add(field string, format string, a ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+// maxMultipartMemory is the maxMemory argument of `Request.ParseMultipartForm()` used by Store.Bind().
+const maxMultipartMemory = 32 << 20
+
+var (
+	fileHeaderType      = reflect.TypeFor[*multipart.FileHeader]()
+	fileHeaderSliceType = reflect.TypeFor[[]*multipart.FileHeader]()
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Bind decodes request into the struct pointed to by v, and then validates it.
+// Sources are applied by the following order, so latter ones take precedence:
+//   - request body, 'application/json' into fields with `json` tag (same as `json.Unmarshal()`)
+//   - request body, 'application/x-www-form-urlencoded' into fields with `form` tag
+//   - request body, 'multipart/form-data' into fields with `form` tag, including `*multipart.FileHeader` and `[]*multipart.FileHeader`
+//   - query string into fields with `query` tag
+//   - route params into fields with `param` tag
+//
+// Field of form, query or param could be string, bool, int*, uint*, float*, time.Duration,
+// encoding.TextUnmarshaler, pointer to them or slice of them. Fields of embedded structs are also bound.
+//
+// Fields are validated by `validate` tag, whose rules are separated by comma:
+//   - required: field must not be zero value
+//   - min=n: number must be at least n, or length of string/slice/map must be at least n
+//   - max=n: number must be at most n, or length of string/slice/map must be at most n
+//   - enum=a|b|c: field must be one of the values
+//   - regex=pattern: string must match the pattern, it must be the last rule as pattern may contain comma
+//
+// Rules other than `required` are skipped for zero value, so use pointer to validate optional number.
+// Nested struct fields are validated recursively. Returned error is *BindError if request is invalid.
+// Errors of reading request body are returned as they are, e.g. *http.MaxBytesError from MaxBodySize().
+// Malformed `validate` tags are reported as error, they are checked only once for each struct type.
+func (store *Store) Bind(v any) error {
+	pVal := reflect.ValueOf(v)
+	if pVal.Kind() != reflect.Pointer || pVal.IsNil() {
+		return errors.New("httpd: Bind() want non-nil pointer as input argument, but got " + pVal.Kind().String())
+	}
+	structValue := pVal.Elem()
+	if structValue.Kind() != reflect.Struct {
+		return errors.New("httpd: Bind() want pointer to struct, but got pointer to " + structValue.Kind().String())
+	}
+	if err := checkValidateTags(structValue.Type()); err != nil {
+		return err
+	}
+
+	bindErr := &BindError{}
+	if store.R.Body != nil && store.R.Body != http.NoBody {
+		body := &readErrorBody{ReadCloser: store.R.Body}
+		store.R.Body = body
+		defer func() { store.R.Body = body.ReadCloser }()
+
+		mediaType, _, _ := mime.ParseMediaType(store.R.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/json":
+			if err := json.NewDecoder(body).Decode(v); err != nil && err != io.EOF {
+				var typeErr *json.UnmarshalTypeError
+				if body.err != nil {
+					return body.err
+				} else if errors.As(err, &typeErr) && typeErr.Field != "" {
+					bindErr.add(typeErr.Field, "invalid value type %s", typeErr.Value)
+				} else {
+					bindErr.add("body", "invalid json: %s", err.Error())
+				}
+				return bindErr
+			}
+		case "application/x-www-form-urlencoded":
+			if err := store.R.ParseForm(); err != nil {
+				if body.err != nil {
+					return body.err
+				}
+				bindErr.add("body", "invalid form: %s", err.Error())
+				return bindErr
+			}
+			bindValues(structValue, "form", func(key string) []string { return store.R.PostForm[key] }, nil, bindErr)
+		case "multipart/form-data":
+			if err := store.R.ParseMultipartForm(maxMultipartMemory); err != nil {
+				if body.err != nil {
+					return body.err
+				}
+				bindErr.add("body", "invalid multipart form: %s", err.Error())
+				return bindErr
+			}
+			form := store.R.MultipartForm
+			bindValues(structValue, "form", func(key string) []string { return form.Value[key] }, form.File, bindErr)
+		}
+	}
+	query := store.R.URL.Query()
+	bindValues(structValue, "query", func(key string) []string { return query[key] }, nil, bindErr)
+	bindValues(structValue, "param", func(key string) []string {
+		if v, ok := store.P.Get(key); ok {
+			return []string{v}
+		}
+		return nil
+	}, nil, bindErr)
+	if len(bindErr.Fields) > 0 {
+		return bindErr
+	}
+
+	validateStruct(structValue, "", bindErr)
+	if len(bindErr.Fields) > 0 {
+		return bindErr
+	}
+	return nil
+}
+
+// readErrorBody records the error of reading request body, to tell it apart from decoding error of request.
+type readErrorBody struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *readErrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func bindValues(structValue reflect.Value, tagKey string, lookup func(string) []string, files map[string][]*multipart.FileHeader, bindErr *BindError) {
+	structType := structValue.Type()
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindValues(structValue.Field(i), tagKey, lookup, files, bindErr)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tagKey), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		fieldValue := structValue.Field(i)
+		if field.Type == fileHeaderType || field.Type == fileHeaderSliceType {
+			if fhs := files[name]; len(fhs) > 0 && field.Type == fileHeaderType {
+				fieldValue.Set(reflect.ValueOf(fhs[0]))
+			} else if len(fhs) > 0 {
+				fieldValue.Set(reflect.ValueOf(fhs))
+			}
+			continue
+		}
+		values := lookup(name)
+		if len(values) == 0 {
+			continue
+		}
+		if err := setValues(fieldValue, values); err != nil {
+			bindErr.add(name, "invalid value %q", values[0])
+		}
+	}
+}
+
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && !v.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i := range values {
+			if err := setValue(slice.Index(i), values[i]); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, values[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		v.SetInt(int64(d))
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return errors.New("httpd: unsupported field type " + v.Type().String())
+	}
+	return nil
+}
+
+// fieldName returns the name used in FieldError, which is the first tag name of param, query, form and json.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"param", "query", "form", "json"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func validateStruct(structValue reflect.Value, prefix string, bindErr *BindError) {
+	structType := structValue.Type()
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		fieldValue := structValue.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			validateStruct(fieldValue, prefix, bindErr)
+			continue
+		} else if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + fieldName(field)
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if msg := validateValue(fieldValue, rules); msg != "" {
+				bindErr.add(name, "%s", msg)
+				continue
+			}
+		}
+		if elem := reflect.Indirect(fieldValue); elem.Kind() == reflect.Struct && !elem.Addr().Type().Implements(textUnmarshalerType) {
+			validateStruct(elem, name+".", bindErr)
+		}
+	}
+}
+
+// splitRules splits validate tag into rules. The regex rule consumes the rest of tag.
+func splitRules(rules string) (result []string) {
+	for rules != "" {
+		var rule string
+		if strings.HasPrefix(rules, "regex=") {
+			rule, rules = rules, ""
+		} else {
+			rule, rules, _ = strings.Cut(rules, ",")
+		}
+		result = append(result, rule)
+	}
+	return result
+}
+
+var validateTagCache sync.Map // map[reflect.Type]error
+
+// checkValidateTags returns error for the first malformed `validate` tag of struct type, and caches the result.
+func checkValidateTags(structType reflect.Type) error {
+	if v, ok := validateTagCache.Load(structType); ok {
+		err, _ := v.(error)
+		return err
+	}
+	err := checkStructTags(structType, make(map[reflect.Type]bool))
+	validateTagCache.Store(structType, err)
+	return err
+}
+
+func checkStructTags(structType reflect.Type, visited map[reflect.Type]bool) error {
+	if visited[structType] {
+		return nil
+	}
+	visited[structType] = true
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := checkStructTags(field.Type, visited); err != nil {
+				return err
+			}
+			continue
+		} else if !field.IsExported() {
+			continue
+		}
+
+		elemType := field.Type
+		if elemType.Kind() == reflect.Pointer {
+			elemType = elemType.Elem()
+		}
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if err := checkRules(elemType, rules); err != nil {
+				return errors.New("httpd: invalid validate tag of " + structType.String() + "." + field.Name + ": " + err.Error())
+			}
+		}
+		if elemType.Kind() == reflect.Struct && !reflect.PointerTo(elemType).Implements(textUnmarshalerType) {
+			if err := checkStructTags(elemType, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRules(elemType reflect.Type, tag string) error {
+	for _, rule := range splitRules(tag) {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required", "enum":
+		case "min", "max":
+			if _, err := strconv.ParseFloat(arg, 64); err != nil {
+				return errors.New("invalid number in rule " + rule)
+			} else if !isNumberOrLen(elemType.Kind()) {
+				return errors.New("rule " + rule + " is unsupported for type " + elemType.String())
+			}
+		case "regex":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return err
+			}
+			regexpCache.Store(arg, re)
+		default:
+			return errors.New("unknown rule " + rule)
+		}
+	}
+	return nil
+}
+
+// validateValue returns the message of the first failed rule, or empty string if all rules passed.
+// The rules have been checked by checkValidateTags().
+func validateValue(v reflect.Value, tag string) string {
+	rules := splitRules(tag)
+	if v.IsZero() {
+		if slices.Contains(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+	v = reflect.Indirect(v)
+
+	for _, rule := range rules {
+		key, arg, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+		case "min", "max":
+			limit, _ := strconv.ParseFloat(arg, 64)
+			n, isLen := numberOrLen(v)
+			prefix := "must be"
+			if isLen {
+				prefix = "length must be"
+			}
+			if key == "min" && n < limit {
+				return prefix + " at least " + arg
+			} else if key == "max" && n > limit {
+				return prefix + " at most " + arg
+			}
+		case "enum":
+			if !slices.Contains(strings.Split(arg, "|"), fmt.Sprint(v.Interface())) {
+				return "must be one of " + strings.ReplaceAll(arg, "|", ", ")
+			}
+		case "regex":
+			if !cachedRegexp(arg).MatchString(fmt.Sprint(v.Interface())) {
+				return "must match " + arg
+			}
+		}
+	}
+	return ""
+}
+
+func numberOrLen(v reflect.Value) (n float64, isLen bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
+
+// isNumberOrLen reports whether kind is supported by numberOrLen().
+func isNumberOrLen(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+var regexpCache sync.Map // map[string]*regexp.Regexp
+
+func cachedRegexp(pattern string) *regexp.Regexp {
+	if re, ok := regexpCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	regexpCache.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,177 @@
+package httpd_test
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+type bindPage struct {
+	Page int `query:"page" validate:"min=1"`
+	Size int `query:"size" validate:"max=100"`
+}
+
+type bindUser struct {
+	bindPage
+	ID      int           `param:"id" validate:"required"`
+	Name    string        `json:"name" form:"name" validate:"required,min=2,max=8"`
+	Role    string        `json:"role" form:"role" validate:"enum=admin|user"`
+	Email   string        `json:"email" form:"email" validate:"regex=^[a-z]+@[a-z]+\\.(com|org)$"`
+	Age     *int          `json:"age" form:"age" validate:"min=0"`
+	Tags    []string      `json:"tags" query:"tag" validate:"max=2"`
+	Timeout time.Duration `query:"timeout"`
+	Since   time.Time     `query:"since"`
+	Profile struct {
+		Bio string `json:"bio" validate:"max=4"`
+	} `json:"profile"`
+}
+
+func serveBind(t *testing.T, r *http.Request, v any) (err error) {
+	t.Helper()
+	mux := httpd.NewMux()
+	mux.Handle("/users/:id", httpd.MethodAll, func(s *httpd.Store) { err = s.Bind(v) })
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	return err
+}
+
+func TestBindJson(t *testing.T) {
+	body := `{"name":"tom","role":"admin","email":"tom@example.com","age":0,"tags":["a"],"profile":{"bio":"hi"}}`
+	r := httptest.NewRequest(http.MethodPost, "/users/10?page=2&size=20&tag=b&tag=c&timeout=1m&since=2000-01-02T03:04:05Z", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	var user bindUser
+	if err := serveBind(t, r, &user); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if user.ID != 10 || user.Name != "tom" || user.Role != "admin" || user.Email != "tom@example.com" || user.Age == nil || *user.Age != 0 {
+		t.Fatalf("Bind() got %+v", user)
+	}
+	if user.Page != 2 || user.Size != 20 || !slices.Equal(user.Tags, []string{"b", "c"}) || user.Timeout != time.Minute || user.Profile.Bio != "hi" {
+		t.Fatalf("Bind() got %+v", user)
+	}
+	if want := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC); !user.Since.Equal(want) {
+		t.Fatalf("Bind() got since %v, want %v", user.Since, want)
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/users/10", strings.NewReader("name=tom&role=user&age=18"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var user bindUser
+	if err := serveBind(t, r, &user); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if user.ID != 10 || user.Name != "tom" || user.Role != "user" || user.Age == nil || *user.Age != 18 {
+		t.Fatalf("Bind() got %+v", user)
+	}
+}
+
+func TestBindMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("title", "hello")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("content"))
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/users/10", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var upload struct {
+		Title string                  `form:"title" validate:"required"`
+		File  *multipart.FileHeader   `form:"file" validate:"required"`
+		Files []*multipart.FileHeader `form:"file"`
+	}
+	if err := serveBind(t, r, &upload); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	if upload.Title != "hello" || upload.File == nil || upload.File.Filename != "a.txt" || len(upload.Files) != 1 {
+		t.Fatalf("Bind() got %+v", upload)
+	}
+}
+
+func TestBindError(t *testing.T) {
+	tests := []struct {
+		url    string
+		body   string
+		fields []httpd.FieldError
+	}{
+		{"/users/10", `{"name":`, []httpd.FieldError{{Field: "body", Message: "invalid json: unexpected EOF"}}},
+		{"/users/10", `{"name":1}`, []httpd.FieldError{{Field: "name", Message: "invalid value type number"}}},
+		{"/users/abc?page=x", `{"name":"tom"}`, []httpd.FieldError{{Field: "page", Message: `invalid value "x"`}, {Field: "id", Message: `invalid value "abc"`}}},
+		{"/users/0?page=0&size=101", `{"role":"guest","email":"a@b.net","age":-1,"tags":["a","b","c"],"profile":{"bio":"hello"}}`, []httpd.FieldError{
+			{Field: "size", Message: "must be at most 100"},
+			{Field: "id", Message: "is required"},
+			{Field: "name", Message: "is required"},
+			{Field: "role", Message: "must be one of admin, user"},
+			{Field: "email", Message: `must match ^[a-z]+@[a-z]+\.(com|org)$`},
+			{Field: "age", Message: "must be at least 0"},
+			{Field: "tag", Message: "length must be at most 2"},
+			{Field: "profile.bio", Message: "length must be at most 4"},
+		}},
+		{"/users/10", `{"name":"t"}`, []httpd.FieldError{{Field: "name", Message: "length must be at least 2"}}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/json")
+
+		var bindErr *httpd.BindError
+		if err := serveBind(t, r, &bindUser{}); !errors.As(err, &bindErr) {
+			t.Fatalf("Bind(%q, %q) return %v, want *BindError", tt.url, tt.body, err)
+		}
+		if !slices.Equal(bindErr.Fields, tt.fields) {
+			t.Fatalf("Bind(%q, %q) return %+v, want %+v", tt.url, tt.body, bindErr.Fields, tt.fields)
+		}
+	}
+}
+
+func TestBindInvalidTag(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{&struct {
+			Age int `query:"age" validate:"min=x"`
+		}{}, "Age: invalid number in rule min=x"},
+		{&struct {
+			Name string `query:"name" validate:"required,email"`
+		}{}, "unknown rule email"},
+		{&struct {
+			On bool `query:"on" validate:"max=1"`
+		}{}, "rule max=1 is unsupported for type bool"},
+		{&struct {
+			Inner struct {
+				Code string `json:"code" validate:"regex=[a-"`
+			}
+		}{}, "error parsing regexp"},
+	}
+	for _, tt := range tests {
+		for range 2 { // result is cached after the first call
+			r := httptest.NewRequest(http.MethodGet, "/users/10?age=1&name=a&on=true", nil)
+			if err := serveBind(t, r, tt.v); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Bind(%T) return %v, want error containing %q", tt.v, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestBindInvalidArgument(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/users/10", nil)
+	var user bindUser
+	if err := serveBind(t, r, user); err == nil {
+		t.Fatal("Bind(struct) should fail")
+	}
+	var n int
+	if err := serveBind(t, r, &n); err == nil {
+		t.Fatal("Bind(*int) should fail")
+	}
+}
@@ -0,0 +1,297 @@
+package httpd
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoder creates compressing writer for a content-coding.
+// The writer should implement `Flush() error` to support flushing, like gzip.Writer and flate.Writer.
+type Encoder struct {
+	Name string // content-coding name, e.g. "gzip"
+	New  func(w io.Writer) io.WriteCloser
+}
+
+// CompressOptions configures the Compress middleware.
+type CompressOptions struct {
+	// Encoders in order of server preference, default zstd, gzip and deflate with Level.
+	Encoders []Encoder
+
+	Level     int      // compression level of default encoders, default flate.DefaultCompression
+	MinLength int      // responses shorter than MinLength are not compressed, default 1024
+	SkipTypes []string // prefixes of content types that are not compressed, default images, videos, archives, etc.
+}
+
+var defaultSkipTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-xz",
+}
+
+// GzipEncoder returns the gzip Encoder with given compression level. Writers are pooled for reuse.
+func GzipEncoder(level int) Encoder {
+	var pool sync.Pool
+	return Encoder{Name: "gzip", New: func(w io.Writer) io.WriteCloser {
+		zw, ok := pool.Get().(*gzip.Writer)
+		if ok {
+			zw.Reset(w)
+		} else if zw, _ = gzip.NewWriterLevel(w, level); zw == nil {
+			zw = gzip.NewWriter(w) // fallback to default level for invalid level
+		}
+		return &pooledWriter{zw, func() { pool.Put(zw) }}
+	}}
+}
+
+// DeflateEncoder returns the deflate Encoder with given compression level. Writers are pooled for reuse.
+func DeflateEncoder(level int) Encoder {
+	var pool sync.Pool
+	return Encoder{Name: "deflate", New: func(w io.Writer) io.WriteCloser {
+		fw, ok := pool.Get().(*flate.Writer)
+		if ok {
+			fw.Reset(w)
+		} else if fw, _ = flate.NewWriter(w, level); fw == nil {
+			fw, _ = flate.NewWriter(w, flate.DefaultCompression)
+		}
+		return &pooledWriter{fw, func() { pool.Put(fw) }}
+	}}
+}
+
+// ZstdEncoder returns the zstd Encoder with given compression level, which is mapped to the nearest zstd speed,
+// e.g. 1 for fastest and 9 for best compression. Non-positive level means default. Writers are pooled for reuse.
+func ZstdEncoder(level int) Encoder {
+	encoderLevel := zstd.SpeedDefault
+	if level > 0 {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	var pool sync.Pool
+	return Encoder{Name: "zstd", New: func(w io.Writer) io.WriteCloser {
+		zw, ok := pool.Get().(*zstd.Encoder)
+		if ok {
+			zw.Reset(w)
+		} else {
+			// window of 8MB at most is required for HTTP by RFC 9659
+			zw, _ = zstd.NewWriter(w, zstd.WithEncoderLevel(encoderLevel), zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+		}
+		return &pooledWriter{zw, func() { pool.Put(zw) }}
+	}}
+}
+
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type pooledWriter struct {
+	flushWriteCloser
+	release func()
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.flushWriteCloser.Close()
+	w.release()
+	return err
+}
+
+// Compress returns a middleware that compresses response body with the encoding negotiated by 'Accept-Encoding'.
+// It replaces store.W.Origin with a compressing writer during the handler, so compression is transparent to
+// handlers and store.W.Status still works. Responses are not compressed if they are shorter than MinLength,
+// have 'Content-Encoding' already, or match SkipTypes. Status of 204, 206 and 304 are also skipped.
+func Compress(opts CompressOptions) HandlerFunc {
+	if opts.Level == 0 {
+		opts.Level = flate.DefaultCompression
+	}
+	if len(opts.Encoders) == 0 {
+		opts.Encoders = []Encoder{ZstdEncoder(opts.Level), GzipEncoder(opts.Level), DeflateEncoder(opts.Level)}
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = 1024
+	}
+	if opts.SkipTypes == nil {
+		opts.SkipTypes = defaultSkipTypes
+	}
+
+	return func(store *Store) {
+		store.W.Header().Add("Vary", "Accept-Encoding")
+		accept := store.R.Header.Get("Accept-Encoding")
+		var encoder *Encoder
+		for i := range opts.Encoders {
+			if acceptEncoding(accept, opts.Encoders[i].Name) {
+				encoder = &opts.Encoders[i]
+				break
+			}
+		}
+		if encoder == nil || store.R.Method == http.MethodHead {
+			store.Next()
+			return
+		}
+
+		cw := &compressWriter{origin: store.W.Origin, encoder: encoder, opts: &opts}
+		store.W.Origin = cw
+		defer func() {
+			store.W.Origin = cw.origin
+			cw.Close()
+		}()
+		store.Next()
+	}
+}
+
+// compressWriter buffers the beginning of response body to decide whether to compress.
+type compressWriter struct {
+	origin  http.ResponseWriter
+	encoder *Encoder
+	opts    *CompressOptions
+
+	code    int
+	buf     []byte
+	decided bool
+	writer  io.WriteCloser // nil if response is not compressed
+}
+
+func (cw *compressWriter) Header() http.Header {
+	return cw.origin.Header()
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		cw.origin.WriteHeader(code) // informational headers are sent immediately
+		return
+	}
+	if cw.code == 0 {
+		cw.code = code
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.opts.MinLength {
+			return len(p), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+	return cw.origin.Write(p)
+}
+
+// decide sends response header to origin, and then writes the buffered body.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	if cw.code == 0 {
+		cw.code = http.StatusOK
+	}
+	h := cw.origin.Header()
+	if len(cw.buf) > 0 && h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(cw.buf)) // avoid sniffing compressed body
+	}
+	if cw.shouldCompress() {
+		h.Set("Content-Encoding", cw.encoder.Name)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.writer = cw.encoder.New(cw.origin)
+	}
+	cw.origin.WriteHeader(cw.code)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	} else if cw.writer != nil {
+		_, err := cw.writer.Write(buf)
+		return err
+	}
+	_, err := cw.origin.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	if len(cw.buf) < cw.opts.MinLength {
+		return false
+	}
+	switch cw.code {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	h := cw.origin.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	ctype := h.Get("Content-Type")
+	for _, prefix := range cw.opts.SkipTypes {
+		if strings.HasPrefix(ctype, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close writes the buffered body if not decided, and finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.decided && cw.code != 0 {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.origin
+}
+
+// Hijack implements the standard http.Hijacker interface.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.origin.(http.Hijacker); ok {
+		cw.decided = true // nothing should be written after hijacking
+		return hj.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Flush implements the standard http.Flusher interface.
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+// FlushError sends the buffered body and flushes both the compressing writer and origin.
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if fw, ok := cw.writer.(interface{ Flush() error }); ok {
+		if err := fw.Flush(); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := cw.origin.(interface{ FlushError() error }); ok {
+		return flusher.FlushError()
+	} else if flusher, ok := cw.origin.(http.Flusher); ok {
+		flusher.Flush()
+		return nil
+	}
+	return http.ErrNotSupported
+}
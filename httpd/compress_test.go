@@ -0,0 +1,163 @@
+package httpd_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/whoisnian/glb/httpd"
+)
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(body)
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("gzip.NewReader error: %v", err)
+		}
+		r = zr
+	case "deflate":
+		r = flate.NewReader(r)
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			t.Fatalf("zstd.NewReader error: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress %s error: %v", encoding, err)
+	}
+	return string(data)
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+	tests := []struct {
+		path     string
+		accept   string
+		encoding string
+		ctype    string
+		body     string
+	}{
+		{"/text", "", "", "", large},
+		{"/text", "gzip, deflate", "gzip", "text/plain; charset=utf-8", large},
+		{"/text", "deflate;q=0.5, gzip;q=0", "deflate", "text/plain; charset=utf-8", large},
+		{"/text", "br", "", "", large},
+		{"/text", "*", "zstd", "text/plain; charset=utf-8", large},
+		{"/text", "gzip, deflate, br, zstd", "zstd", "text/plain; charset=utf-8", large},
+		{"/small", "gzip", "", "text/plain; charset=utf-8", "small"},
+		{"/image", "gzip", "", "image/png", large},
+		{"/encoded", "gzip", "br", "text/plain", large},
+	}
+
+	var status int
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(func(s *httpd.Store) {
+		s.Next()
+		status = s.W.Status
+	}, httpd.Compress(httpd.CompressOptions{}))
+	mux.Handle("/text", http.MethodGet, func(s *httpd.Store) {
+		s.W.WriteHeader(http.StatusCreated)
+		for i := 0; i < 200; i++ {
+			s.W.Write([]byte("hello world "))
+		}
+	})
+	mux.Handle("/small", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("small")) })
+	mux.Handle("/image", http.MethodGet, func(s *httpd.Store) {
+		s.W.Header().Set("Content-Type", "image/png")
+		s.W.Write([]byte(large))
+	})
+	mux.Handle("/encoded", http.MethodGet, func(s *httpd.Store) {
+		s.W.Header().Set("Content-Type", "text/plain")
+		s.W.Header().Set("Content-Encoding", "br")
+		s.W.Write([]byte(large))
+	})
+	for _, tt := range tests {
+		status = 0
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Fatalf("%s %q return Content-Encoding %q, want %q", tt.path, tt.accept, got, tt.encoding)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Fatalf("%s %q return Vary %q, want %q", tt.path, tt.accept, got, "Accept-Encoding")
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.ctype {
+			t.Fatalf("%s %q return Content-Type %q, want %q", tt.path, tt.accept, got, tt.ctype)
+		}
+		if got := decompress(t, tt.encoding, w.Body.Bytes()); got != tt.body {
+			t.Fatalf("%s %q return body of length %d, want %d", tt.path, tt.accept, len(got), len(tt.body))
+		}
+		if status != w.Code || (tt.path == "/text" && status != http.StatusCreated) {
+			t.Fatalf("%s %q recorded status %d, want %d", tt.path, tt.accept, status, w.Code)
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.Compress(httpd.CompressOptions{MinLength: 1}))
+	mux.Handle("/stream", http.MethodGet, func(s *httpd.Store) {
+		s.W.Write([]byte("first"))
+		s.W.Flush()
+		s.W.Write([]byte("second"))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Flushed = %v Content-Encoding = %q, want %v %q", w.Flushed, w.Header().Get("Content-Encoding"), true, "gzip")
+	}
+	if got := decompress(t, "gzip", w.Body.Bytes()); got != "firstsecond" {
+		t.Fatalf("body = %q, want %q", got, "firstsecond")
+	}
+}
+
+func TestCompressCustomEncoder(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.Compress(httpd.CompressOptions{
+		MinLength: 1,
+		Encoders: []httpd.Encoder{
+			{Name: "zstd", New: func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }},
+			httpd.GzipEncoder(gzip.BestSpeed),
+		},
+	}))
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("content")) })
+
+	for _, accept := range []string{"gzip, zstd", "gzip"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		encoding := w.Header().Get("Content-Encoding")
+		if want := accept[len(accept)-4:]; encoding != want {
+			t.Fatalf("Accept-Encoding %q return Content-Encoding %q, want %q", accept, encoding, want)
+		}
+		if encoding == "zstd" && w.Body.String() != "content" {
+			t.Fatalf("Accept-Encoding %q return body %q, want %q", accept, w.Body.String(), "content")
+		}
+		if encoding == "gzip" && decompress(t, "gzip", w.Body.Bytes()) != "content" {
+			t.Fatalf("Accept-Encoding %q return unexpected gzip body", accept)
+		}
+	}
+}
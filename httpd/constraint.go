@@ -0,0 +1,75 @@
+package httpd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseParamFragment parses route fragment like `:name` or `:name<constraint>`.
+// The constraint can be one of the builtin types or a regular expression without '/':
+//   - `int`:  decimal integer which can be parsed by `strconv.Atoi()`
+//   - `uint`: decimal unsigned integer which can be parsed by `strconv.ParseUint()`
+//   - `uuid`: hex-encoded uuid like `123e4567-e89b-12d3-a456-426614174000`
+//   - others: regular expression that must match the whole fragment, e.g. `[a-z0-9-]+`
+func parseParamFragment(fragment string) (name string, constraint string, ok bool) {
+	name = fragment[1:]
+	if i := strings.IndexByte(name, '<'); i >= 0 {
+		if name[len(name)-1] != '>' {
+			return "", "", false
+		}
+		name, constraint = name[:i], name[i+1:len(name)-1]
+		if constraint == "" {
+			return "", "", false
+		}
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return name, constraint, true
+}
+
+func compileConstraint(constraint string) (func(string) bool, error) {
+	switch constraint {
+	case "int":
+		return isInt, nil
+	case "uint":
+		return isUint, nil
+	case "uuid":
+		return isUUID, nil
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+func isInt(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+func isUint(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 0)
+	return err == nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := range len(s) {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f' || 'A' <= s[i] && s[i] <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package httpd
+
+import "testing"
+
+func TestParseParamFragment(t *testing.T) {
+	tests := []struct {
+		fragment   string
+		name       string
+		constraint string
+		ok         bool
+	}{
+		{":id", "id", "", true},
+		{":id<int>", "id", "int", true},
+		{":slug<[a-z0-9-]+>", "slug", "[a-z0-9-]+", true},
+		{":", "", "", false},
+		{":<int>", "", "", false},
+		{":id<>", "", "", false},
+		{":id<int", "", "", false},
+	}
+	for _, tt := range tests {
+		name, constraint, ok := parseParamFragment(tt.fragment)
+		if name != tt.name || constraint != tt.constraint || ok != tt.ok {
+			t.Fatalf("parseParamFragment(%q) = %q %q %v, want %q %q %v", tt.fragment, name, constraint, ok, tt.name, tt.constraint, tt.ok)
+		}
+	}
+}
+
+func TestCompileConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		input      string
+		want       bool
+	}{
+		{"int", "10", true},
+		{"int", "-10", true},
+		{"int", "1.5", false},
+		{"int", "", false},
+		{"uint", "10", true},
+		{"uint", "-10", false},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", true},
+		{"uuid", "123E4567-E89B-12D3-A456-426614174000", true},
+		{"uuid", "123e4567-e89b-12d3-a456-42661417400", false},
+		{"uuid", "123e4567e-89b-12d3-a456-426614174000", false},
+		{"uuid", "123e4567-e89b-12d3-a456-42661417400g", false},
+		{"[a-z0-9-]+", "hello-world-1", true},
+		{"[a-z0-9-]+", "Hello", false},
+		{"[a-z0-9-]+", "", false},
+		{"a|bc", "bc", true},
+		{"a|bc", "abc", false},
+	}
+	for _, tt := range tests {
+		check, err := compileConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("compileConstraint(%q): %v", tt.constraint, err)
+		}
+		if got := check(tt.input); got != tt.want {
+			t.Fatalf("constraint <%s> check(%q) = %v, want %v", tt.constraint, tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := compileConstraint("[a-z"); err == nil {
+		t.Fatalf("compileConstraint(%q) should fail", "[a-z")
+	}
+}
@@ -0,0 +1,112 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowOrigins can be exact origin like "https://example.com", wildcard subdomain like "https://*.example.com",
+	// or "*" to allow all origins.
+	AllowOrigins []string
+	// AllowOriginFunc is checked if origin is not matched by AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+
+	AllowMethods     []string      // default: GET, HEAD, POST, PUT, PATCH, DELETE
+	AllowHeaders     []string      // default: reflect 'Access-Control-Request-Headers' of preflight request
+	ExposeHeaders    []string      // response headers that can be accessed by client scripts
+	AllowCredentials bool          // allow cookies and credentials, then "*" origin will be reflected as request origin
+	MaxAge           time.Duration // how long the preflight result can be cached, ignored if zero
+}
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// CORS returns a middleware that sets CORS headers for allowed origins and answers preflight requests with 204.
+// Preflight requests from disallowed origins are passed to the next handler without CORS headers.
+func CORS(opts CORSOptions) HandlerFunc {
+	var allowAll bool
+	var exact, suffixes []string // suffixes are wildcard subdomains split as "https://" and ".example.com"
+	for _, origin := range opts.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		} else if scheme, host, ok := strings.Cut(origin, "://*."); ok {
+			suffixes = append(suffixes, strings.ToLower(scheme+"://"), strings.ToLower("."+host))
+		} else {
+			exact = append(exact, strings.ToLower(origin))
+		}
+	}
+	allowed := func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		lower := strings.ToLower(origin)
+		for _, o := range exact {
+			if lower == o {
+				return true
+			}
+		}
+		for i := 0; i < len(suffixes); i += 2 {
+			if strings.HasPrefix(lower, suffixes[i]) && strings.HasSuffix(lower, suffixes[i+1]) && len(lower) > len(suffixes[i])+len(suffixes[i+1]) {
+				return true
+			}
+		}
+		return opts.AllowOriginFunc != nil && opts.AllowOriginFunc(origin)
+	}
+
+	methods := opts.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(opts.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposeHeaders, ", ")
+	maxAge := strconv.FormatInt(int64(opts.MaxAge/time.Second), 10)
+	varyOrigin := !allowAll || opts.AllowCredentials // response depends on request origin
+
+	return func(store *Store) {
+		h := store.W.Header()
+		origin := store.R.Header.Get("Origin")
+		preflight := store.R.Method == http.MethodOptions && origin != "" && store.R.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Origin")
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		} else if varyOrigin {
+			h.Add("Vary", "Origin")
+		}
+		if origin == "" || !allowed(origin) {
+			store.Next()
+			return
+		}
+
+		if allowAll && !opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			store.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if reqHeaders := store.R.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+		if opts.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		store.W.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,129 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestCORS(t *testing.T) {
+	opts := httpd.CORSOptions{
+		AllowOrigins:     []string{"https://example.com", "https://*.example.org"},
+		AllowOriginFunc:  func(origin string) bool { return strings.HasSuffix(origin, ".internal") },
+		ExposeHeaders:    []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+	tests := []struct {
+		method string
+		header http.Header
+		code   int
+		body   string
+		want   http.Header
+	}{
+		{http.MethodGet, http.Header{}, 200, "get", http.Header{"Vary": {"Origin"}, "Access-Control-Allow-Origin": nil}},
+		{http.MethodGet, http.Header{"Origin": {"https://example.com"}}, 200, "get", http.Header{
+			"Vary":                             {"Origin"},
+			"Access-Control-Allow-Origin":      {"https://example.com"},
+			"Access-Control-Allow-Credentials": {"true"},
+			"Access-Control-Expose-Headers":    {"X-Request-ID"},
+		}},
+		{http.MethodGet, http.Header{"Origin": {"https://a.b.example.org"}}, 200, "get", http.Header{"Access-Control-Allow-Origin": {"https://a.b.example.org"}}},
+		{http.MethodGet, http.Header{"Origin": {"https://.example.org"}}, 200, "get", http.Header{"Access-Control-Allow-Origin": nil}},
+		{http.MethodGet, http.Header{"Origin": {"http://a.example.org"}}, 200, "get", http.Header{"Access-Control-Allow-Origin": nil}},
+		{http.MethodGet, http.Header{"Origin": {"https://evil.com"}}, 200, "get", http.Header{"Vary": {"Origin"}, "Access-Control-Allow-Origin": nil}},
+		{http.MethodGet, http.Header{"Origin": {"http://app.internal"}}, 200, "get", http.Header{"Access-Control-Allow-Origin": {"http://app.internal"}}},
+		{http.MethodOptions, http.Header{"Origin": {"https://example.com"}, "Access-Control-Request-Method": {"PUT"}, "Access-Control-Request-Headers": {"content-type"}}, 204, "", http.Header{
+			"Vary":                          {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+			"Access-Control-Allow-Origin":   {"https://example.com"},
+			"Access-Control-Allow-Methods":  {"GET, HEAD, POST, PUT, PATCH, DELETE"},
+			"Access-Control-Allow-Headers":  {"content-type"},
+			"Access-Control-Max-Age":        {"3600"},
+			"Access-Control-Expose-Headers": nil,
+		}},
+		{http.MethodOptions, http.Header{"Origin": {"https://evil.com"}, "Access-Control-Request-Method": {"PUT"}}, 204, "", http.Header{
+			"Allow":                        {"GET, HEAD, OPTIONS"},
+			"Access-Control-Allow-Origin":  nil,
+			"Access-Control-Allow-Methods": nil,
+		}},
+		{http.MethodOptions, http.Header{"Origin": {"https://example.com"}}, 204, "", http.Header{
+			"Allow":                        {"GET, HEAD, OPTIONS"},
+			"Access-Control-Allow-Origin":  {"https://example.com"},
+			"Access-Control-Allow-Methods": nil,
+		}},
+	}
+
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.CORS(opts))
+	mux.Handle("/api", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte("get")) })
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/api", nil)
+		r.Header = tt.header
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Fatalf("%s %v return %d %q, want %d %q", tt.method, tt.header, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		for k, v := range tt.want {
+			if got := w.Header().Values(k); strings.Join(got, ",") != strings.Join(v, ",") {
+				t.Fatalf("%s %v return header %s: %q, want %q", tt.method, tt.header, k, got, v)
+			}
+		}
+	}
+}
+
+func TestCORSAllowAll(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.CORS(httpd.CORSOptions{AllowOrigins: []string{"*"}, AllowHeaders: []string{"Content-Type", "Authorization"}}))
+	mux.Handle("/api", http.MethodGet, func(s *httpd.Store) {})
+
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Origin", "https://any.com")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" || w.Header().Get("Vary") != "" {
+		t.Fatalf("Allow-Origin = %q Vary = %q, want %q %q", got, w.Header().Get("Vary"), "*", "")
+	}
+
+	r = httptest.NewRequest(http.MethodOptions, "/api", nil)
+	r.Header.Set("Origin", "https://any.com")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	r.Header.Set("Access-Control-Request-Headers", "x-custom")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Headers"); w.Code != 204 || got != "Content-Type, Authorization" {
+		t.Fatalf("preflight return %d %q, want %d %q", w.Code, got, 204, "Content-Type, Authorization")
+	}
+}
+
+func TestCORSInGroup(t *testing.T) {
+	mux := httpd.NewMux()
+	api := mux.Group("/api", httpd.CORS(httpd.CORSOptions{AllowOrigins: []string{"https://example.com"}}))
+	api.Handle("/items", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte("items")) })
+	mux.Handle("/ping", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte("pong")) })
+
+	tests := []struct {
+		method string
+		url    string
+		code   int
+		origin string
+	}{
+		{http.MethodOptions, "/api/items", 204, "https://example.com"},
+		{http.MethodPost, "/api/items", 405, "https://example.com"},
+		{http.MethodOptions, "/ping", 204, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.url, nil)
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); w.Code != tt.code || got != tt.origin {
+			t.Fatalf("%s %s return %d with origin %q, want %d with %q", tt.method, tt.url, w.Code, got, tt.code, tt.origin)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package httpd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+const csrfTokenLen = 32
+
+// CSRFOptions configures the CSRF middleware.
+type CSRFOptions struct {
+	// UseSession stores token in Store.Session() (synchronizer token pattern), which requires Sessions middleware
+	// registered before CSRF, or CSRF panics on request. Otherwise token is stored in a cookie (double-submit cookie pattern).
+	UseSession bool
+
+	CookieName string // default "csrf_token", also the session key if UseSession
+	HeaderName string // default "X-CSRF-Token"
+	FieldName  string // form field name, default "csrf_token"
+	Path       string // cookie path, default "/"
+	Domain     string
+	Secure     bool
+	SameSite   http.SameSite // default http.SameSiteLaxMode
+
+	TrustedOrigins []string          // origins allowed besides the request host, e.g. "https://app.example.com"
+	Exempt         func(*Store) bool // skip checking, e.g. `func(s *Store) bool { return s.I.Name == "webhook" }`
+	OnFailure      HandlerFunc       // default replies 403 with plain text
+}
+
+// CSRF returns a middleware that protects unsafe methods from cross-site request forgery. Requests other than
+// GET, HEAD, OPTIONS and TRACE must provide the token by HeaderName or FieldName, and must come from the same
+// origin or TrustedOrigins if 'Origin' or 'Referer' header exists. Use Store.CSRFToken() to render the token.
+func CSRF(opts CSRFOptions) HandlerFunc {
+	if opts.CookieName == "" {
+		opts.CookieName = "csrf_token"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if opts.OnFailure == nil {
+		opts.OnFailure = func(store *Store) { http.Error(store.W, "403 forbidden", http.StatusForbidden) }
+	}
+
+	return func(store *Store) {
+		if opts.UseSession && store.Session() == nil {
+			panic("httpd: CSRF with UseSession requires Sessions middleware before it")
+		}
+		// Token is issued before handler, as CSRFToken() may be called after response header is written.
+		token := loadCSRFToken(store, &opts)
+		issued := token == nil
+		if issued {
+			token = make([]byte, csrfTokenLen)
+			rand.Read(token) // crypto/rand.Read() never returns an error
+			saveCSRFToken(store, &opts, token)
+		}
+		store.csrfToken = func() string { return maskCSRFToken(token) }
+
+		switch store.R.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			store.Next()
+			return
+		}
+		if opts.Exempt != nil && opts.Exempt(store) {
+			store.Next()
+			return
+		}
+		if !checkCSRFOrigin(store.R, opts.TrustedOrigins) {
+			opts.OnFailure(store)
+			return
+		}
+		provided := store.R.Header.Get(opts.HeaderName)
+		if provided == "" {
+			provided = store.R.PostFormValue(opts.FieldName)
+		}
+		if issued || !equalCSRFToken(token, provided) {
+			opts.OnFailure(store)
+			return
+		}
+		store.Next()
+	}
+}
+
+// CSRFToken returns a masked CSRF token for forms or headers, which changes every call to mitigate BREACH attack.
+// It returns empty string if CSRF middleware is not used.
+func (store *Store) CSRFToken() string {
+	if store.csrfToken == nil {
+		return ""
+	}
+	return store.csrfToken()
+}
+
+func loadCSRFToken(store *Store, opts *CSRFOptions) []byte {
+	var encoded string
+	if opts.UseSession {
+		encoded, _ = store.Session().Get(opts.CookieName)
+	} else {
+		encoded = store.CookieValue(opts.CookieName)
+	}
+	token, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(token) != csrfTokenLen {
+		return nil
+	}
+	return token
+}
+
+func saveCSRFToken(store *Store, opts *CSRFOptions, token []byte) {
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+	if opts.UseSession {
+		store.Session().Set(opts.CookieName, encoded)
+		return
+	}
+	http.SetCookie(store.W, &http.Cookie{
+		Name:     opts.CookieName,
+		Value:    encoded,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Secure:   opts.Secure,
+		HttpOnly: true,
+		SameSite: opts.SameSite,
+	})
+}
+
+// maskCSRFToken returns base64(pad + (pad XOR token)) with random pad.
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*csrfTokenLen)
+	rand.Read(masked[:csrfTokenLen]) // crypto/rand.Read() never returns an error
+	subtle.XORBytes(masked[csrfTokenLen:], masked[:csrfTokenLen], token)
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func equalCSRFToken(token []byte, provided string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(provided)
+	if err != nil || len(masked) != 2*csrfTokenLen {
+		return false
+	}
+	subtle.XORBytes(masked[csrfTokenLen:], masked[:csrfTokenLen], masked[csrfTokenLen:])
+	return subtle.ConstantTimeCompare(masked[csrfTokenLen:], token) == 1
+}
+
+// checkCSRFOrigin checks 'Origin' header, or 'Referer' header if 'Origin' is absent.
+// Requests without both headers are allowed, and rely on token checking only.
+func checkCSRFOrigin(r *http.Request, trusted []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	if slices.Contains(trusted, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
@@ -0,0 +1,160 @@
+package httpd_test
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func newCSRFMux(opts httpd.CSRFOptions, middlewares ...httpd.HandlerFunc) *httpd.Mux {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(append(middlewares, httpd.CSRF(opts))...)
+	mux.Handle("/form", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte(s.CSRFToken())) })
+	mux.Handle("/submit", http.MethodPost, func(s *httpd.Store) { s.Respond200([]byte("ok")) })
+	mux.Handle("/webhook", http.MethodPost, func(s *httpd.Store) { s.Respond200([]byte("hook")) }).Name = "webhook"
+	return mux
+}
+
+func TestCSRF(t *testing.T) {
+	opts := httpd.CSRFOptions{
+		TrustedOrigins: []string{"https://app.example.com"},
+		Exempt:         func(s *httpd.Store) bool { return s.I.Name == "webhook" },
+	}
+	for _, mux := range []*httpd.Mux{
+		newCSRFMux(opts),
+		newCSRFMux(httpd.CSRFOptions{UseSession: true, TrustedOrigins: opts.TrustedOrigins, Exempt: opts.Exempt},
+			httpd.Sessions(httpd.SessionOptions{Keys: [][]byte{[]byte("key")}})),
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/form", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		token, cookies := w.Body.String(), w.Result().Cookies()
+		if token == "" || len(cookies) != 1 {
+			t.Fatalf("GET /form return token %q with cookies %v", token, cookies)
+		}
+
+		// token is masked differently in every call
+		r = httptest.NewRequest(http.MethodGet, "/form", nil)
+		r.AddCookie(cookies[0])
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Body.String() == token || len(w.Result().Cookies()) != 0 {
+			t.Fatalf("GET /form again return token %q with cookies %v", w.Body.String(), w.Result().Cookies())
+		}
+		anotherToken := w.Body.String()
+
+		tests := []struct {
+			path   string
+			cookie bool
+			header string
+			form   string
+			origin string
+			code   int
+		}{
+			{"/submit", true, token, "", "", 200},
+			{"/submit", true, anotherToken, "", "", 200},
+			{"/submit", true, "", url.Values{"csrf_token": {token}}.Encode(), "", 200},
+			{"/submit", true, token, "", "https://example.com", 200},
+			{"/submit", true, token, "", "https://app.example.com", 200},
+			{"/submit", true, token, "", "https://evil.com", 403},
+			{"/submit", true, token, "", "null", 403},
+			{"/submit", true, "", "", "", 403},
+			{"/submit", true, "invalid", "", "", 403},
+			{"/submit", false, token, "", "", 403},
+			{"/webhook", false, "", "", "https://evil.com", 200},
+		}
+		for _, tt := range tests {
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.form))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie {
+				r.AddCookie(cookies[0])
+			}
+			if tt.header != "" {
+				r.Header.Set("X-CSRF-Token", tt.header)
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.code {
+				t.Fatalf("POST %s (cookie %v, header %q, form %q, origin %q) return %d, want %d", tt.path, tt.cookie, tt.header, tt.form, tt.origin, w.Code, tt.code)
+			}
+		}
+
+		r = httptest.NewRequest(http.MethodPost, "/submit", nil)
+		r.AddCookie(cookies[0])
+		r.Header.Set("X-CSRF-Token", token)
+		r.Header.Set("Referer", "https://evil.com/page")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != 403 {
+			t.Fatalf("POST /submit with cross-site Referer return %d, want 403", w.Code)
+		}
+	}
+}
+
+func TestCSRFTokenWithoutMiddleware(t *testing.T) {
+	if got := (&httpd.Store{}).CSRFToken(); got != "" {
+		t.Fatalf("CSRFToken() without middleware = %q, want empty", got)
+	}
+}
+
+func TestCSRFUseSessionWithoutSessions(t *testing.T) {
+	mux := newCSRFMux(httpd.CSRFOptions{UseSession: true})
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), "requires Sessions middleware") {
+			t.Fatalf("recover() = %v, want panic for missing Sessions middleware", err)
+		}
+	}()
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/form", nil))
+}
+
+func TestCSRFTokenInTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("form").Parse(`<form>{{call .Token}}</form>`))
+	for _, middlewares := range [][]httpd.HandlerFunc{
+		{httpd.CSRF(httpd.CSRFOptions{})},
+		{httpd.Sessions(httpd.SessionOptions{Keys: [][]byte{[]byte("key")}}), httpd.CSRF(httpd.CSRFOptions{UseSession: true})},
+	} {
+		mux := httpd.NewMux()
+		mux.HandleMiddleware(middlewares...)
+		mux.Handle("/form", http.MethodGet, func(s *httpd.Store) {
+			s.W.Write([]byte("<html>")) // response header is written before the token is rendered
+			s.W.Flush()
+			tmpl.Execute(s.W, map[string]any{"Token": s.CSRFToken})
+		})
+		mux.Handle("/submit", http.MethodPost, func(s *httpd.Store) { s.Respond200([]byte("ok")) })
+		server := httptest.NewServer(mux)
+		defer server.Close()
+
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{Jar: jar}
+		resp, err := client.Get(server.URL + "/form")
+		if err != nil {
+			t.Fatalf("GET /form got error %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		token := strings.TrimSuffix(strings.TrimPrefix(string(body), "<html><form>"), "</form>")
+		if token == "" || len(resp.Cookies()) != 1 {
+			t.Fatalf("GET /form return %q with cookies %v, want token with cookie", body, resp.Cookies())
+		}
+
+		resp, err = client.PostForm(server.URL+"/submit", url.Values{"csrf_token": {token}})
+		if err != nil {
+			t.Fatalf("POST /submit got error %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			t.Fatalf("POST /submit with rendered token return %d, want 200", resp.StatusCode)
+		}
+	}
+}
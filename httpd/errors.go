@@ -0,0 +1,169 @@
+package httpd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// KeyError is the key of error returned by handler created by CreateErrorHandler, e.g. for logging.
+const KeyError = "httpd.error"
+
+// HTTPError is an error with status code and public message, which can be returned by handlers
+// created by CreateErrorHandler. The internal cause is never sent to client.
+type HTTPError struct {
+	Status  int
+	Message string // public message, default http.StatusText(Status)
+	Cause   error  // internal cause for logging
+}
+
+// NewHTTPError creates HTTPError with optional public message and internal cause.
+func NewHTTPError(status int, message string, cause error) *HTTPError {
+	return &HTTPError{Status: status, Message: message, Cause: cause}
+}
+
+func (e *HTTPError) Error() string {
+	msg := strconv.Itoa(e.Status) + " " + e.PublicMessage()
+	if e.Cause != nil {
+		return msg + ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Cause
+}
+
+// PublicMessage returns Message, or status text if Message is empty.
+func (e *HTTPError) PublicMessage() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return strings.ToLower(http.StatusText(e.Status))
+}
+
+// CreateErrorHandler converts handler returning error to 'httpd.HandlerFunc'.
+// Non-nil error is stored by Store.Set(KeyError, err) and then passed to the error handler of Mux, see Mux.HandleError().
+func CreateErrorHandler(handler func(*Store) error) HandlerFunc {
+	return func(store *Store) {
+		if err := handler(store); err != nil {
+			store.Set(KeyError, err)
+			if store.mux != nil && store.mux.routeError != nil {
+				store.mux.routeError(store, err)
+			} else {
+				DefaultErrorHandler(store, err)
+			}
+		}
+	}
+}
+
+// HandleError registers the handler for errors returned by handlers created by CreateErrorHandler.
+// The default one is DefaultErrorHandler.
+func (mux *Mux) HandleError(handler func(*Store, error)) {
+	mux.routeError = handler
+}
+
+// DefaultErrorHandler maps error to HTTPError and replies it, unless response header has been written.
+// The following errors are mapped, and others are replied as 500 with the cause hidden:
+//   - *HTTPError as it is
+//   - *BindError as 400 with per-field messages
+//   - *http.MaxBytesError as 413
+//   - context.DeadlineExceeded as 503
+//
+// The response format is negotiated by 'Accept' header among 'text/plain', 'application/json'
+// and 'application/problem+json' (RFC 7807).
+func DefaultErrorHandler(store *Store, err error) {
+	if store.W.Status != 0 {
+		return
+	}
+	var httpErr *HTTPError
+	var bindErr *BindError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &httpErr):
+	case errors.As(err, &bindErr):
+		httpErr = &HTTPError{Status: http.StatusBadRequest, Cause: err}
+	case errors.As(err, &maxBytesErr):
+		httpErr = &HTTPError{Status: http.StatusRequestEntityTooLarge, Cause: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		httpErr = &HTTPError{Status: http.StatusServiceUnavailable, Cause: err}
+	default:
+		httpErr = &HTTPError{Status: http.StatusInternalServerError, Cause: err}
+	}
+
+	header := store.W.Header()
+	header.Del("Content-Length")
+	header.Set("X-Content-Type-Options", "nosniff")
+	switch negotiateContentType(store.R.Header.Get("Accept"), "text/plain", "application/json", "application/problem+json") {
+	case "application/json":
+		body := map[string]any{"status": httpErr.Status, "message": httpErr.PublicMessage()}
+		if bindErr != nil {
+			body["errors"] = bindErr.Fields
+		}
+		store.RespondJson(httpErr.Status, body)
+	case "application/problem+json":
+		problem := map[string]any{
+			"type":     "about:blank",
+			"title":    http.StatusText(httpErr.Status),
+			"status":   httpErr.Status,
+			"detail":   httpErr.PublicMessage(),
+			"instance": store.R.URL.Path,
+		}
+		if bindErr != nil {
+			problem["errors"] = bindErr.Fields
+		}
+		header.Set("Content-Type", "application/problem+json")
+		store.W.WriteHeader(httpErr.Status)
+		json.NewEncoder(store.W).Encode(problem)
+	default:
+		msg := strconv.Itoa(httpErr.Status) + " " + httpErr.PublicMessage()
+		if bindErr != nil {
+			msg += ": " + bindErr.Error()
+		}
+		http.Error(store.W, msg, httpErr.Status)
+	}
+}
+
+// negotiateContentType returns the offer with highest qvalue in 'Accept' header value, or the first offer
+// if header is empty or no offer is acceptable. Specific media range takes precedence over wildcard,
+// and offers in front take precedence when qvalues are equal.
+func negotiateContentType(header string, offers ...string) string {
+	if header == "" {
+		return offers[0]
+	}
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, item := range strings.Split(header, ",") {
+			mediaRange, params, _ := strings.Cut(item, ";")
+			mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+			s := -1
+			switch {
+			case mediaRange == offer:
+				s = 2
+			case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, mediaRange[:len(mediaRange)-1]):
+				s = 1
+			case mediaRange == "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			specificity, q = s, 1.0
+			for param := range strings.SplitSeq(params, ";") {
+				if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						q = f
+					}
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
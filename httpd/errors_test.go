@@ -0,0 +1,91 @@
+package httpd_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestCreateErrorHandler(t *testing.T) {
+	var logged error
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(func(s *httpd.Store) {
+		s.Next()
+		logged, _ = httpd.GetAs[error](s, httpd.KeyError)
+	})
+	mux.Handle("/ok", http.MethodGet, httpd.CreateErrorHandler(func(s *httpd.Store) error { return s.Respond200([]byte("ok")) }))
+	mux.Handle("/404", http.MethodGet, httpd.CreateErrorHandler(func(s *httpd.Store) error {
+		return httpd.NewHTTPError(http.StatusNotFound, "user not found", errors.New("sql: no rows"))
+	}))
+	mux.Handle("/500", http.MethodGet, httpd.CreateErrorHandler(func(s *httpd.Store) error { return errors.New("db password is wrong") }))
+	mux.Handle("/bind", http.MethodPost, httpd.CreateErrorHandler(func(s *httpd.Store) error {
+		var v struct {
+			Name string `json:"name" validate:"required"`
+		}
+		return s.Bind(&v)
+	}))
+	mux.Handle("/written", http.MethodGet, httpd.CreateErrorHandler(func(s *httpd.Store) error {
+		s.W.WriteHeader(http.StatusAccepted)
+		return errors.New("late error")
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		accept string
+		code   int
+		ctype  string
+		body   string
+	}{
+		{http.MethodGet, "/ok", "", 200, "", "ok"},
+		{http.MethodGet, "/404", "", 404, "text/plain; charset=utf-8", "404 user not found\n"},
+		{http.MethodGet, "/404", "application/json", 404, "application/json; charset=utf-8", `{"message":"user not found","status":404}` + "\n"},
+		{http.MethodGet, "/404", "application/problem+json, application/json;q=0.9", 404, "application/problem+json",
+			`{"detail":"user not found","instance":"/404","status":404,"title":"Not Found","type":"about:blank"}` + "\n"},
+		{http.MethodGet, "/404", "text/html, application/*;q=0.5", 404, "application/json; charset=utf-8", `{"message":"user not found","status":404}` + "\n"},
+		{http.MethodGet, "/500", "*/*", 500, "text/plain; charset=utf-8", "500 internal server error\n"},
+		{http.MethodPost, "/bind", "application/json", 400, "application/json; charset=utf-8",
+			`{"errors":[{"field":"name","message":"is required"}],"message":"bad request","status":400}` + "\n"},
+		{http.MethodGet, "/written", "", 202, "", ""},
+	}
+	for _, tt := range tests {
+		logged = nil
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.ctype || w.Body.String() != tt.body {
+			t.Fatalf("%s %s (Accept %q) return %d %q %q, want %d %q %q", tt.method, tt.path, tt.accept, w.Code, w.Header().Get("Content-Type"), w.Body.String(), tt.code, tt.ctype, tt.body)
+		}
+		if (logged == nil) != (tt.path == "/ok") {
+			t.Fatalf("%s %s logged error %v", tt.method, tt.path, logged)
+		}
+	}
+	if !strings.Contains(logged.Error(), "late error") {
+		t.Fatalf("logged error = %v, want %q", logged, "late error")
+	}
+}
+
+func TestHandleError(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleError(func(s *httpd.Store, err error) {
+		var httpErr *httpd.HTTPError
+		if errors.As(err, &httpErr) {
+			s.W.WriteHeader(httpErr.Status)
+			s.W.Write([]byte("custom: " + httpErr.Error()))
+		}
+	})
+	mux.Handle("/", http.MethodGet, httpd.CreateErrorHandler(func(s *httpd.Store) error {
+		return &httpd.HTTPError{Status: http.StatusConflict, Cause: errors.New("duplicate")}
+	}))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if want := "custom: 409 conflict: duplicate"; w.Code != 409 || w.Body.String() != want {
+		t.Fatalf("GET / return %d %q, want %d %q", w.Code, w.Body.String(), 409, want)
+	}
+}
@@ -0,0 +1,232 @@
+package httpd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FileServerOptions configures the behavior of FileServer.
+type FileServerOptions struct {
+	Browse        bool // list directory entries if there is no index.html in directory
+	SPAFallback   bool // serve index.html in root for not found files, used by single page applications
+	Precompressed bool // serve '.br' or '.gz' sibling file if client accepts the encoding
+}
+
+// precompressedExts is the order of precompressed encodings tried by FileServer.
+var precompressedExts = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// FileServer returns a handler that serves files from root, e.g. `os.DirFS(dir)` or `embed.FS`.
+// The file path is the value of route param `/*` if exists, otherwise the request url path.
+// It supports Range requests and conditional requests with 'ETag' and 'Last-Modified'.
+// Paths containing ".." elements or backslashes are rejected with 400 to prevent path traversal.
+func FileServer(root fs.FS, opts FileServerOptions) HandlerFunc {
+	etags := new(sync.Map) // map[etagKey]string, content hashes of files without modification time
+	return func(store *Store) {
+		if store.R.Method != http.MethodGet && store.R.Method != http.MethodHead {
+			store.W.Header().Set("Allow", "GET, HEAD")
+			store.Error405("405 method not allowed")
+			return
+		}
+		rawPath, ok := store.P.Get(routeParamAny)
+		if !ok {
+			rawPath = store.R.URL.Path
+		}
+		name, ok := cleanFilePath(rawPath)
+		if !ok {
+			http.Error(store.W, "400 invalid url path", http.StatusBadRequest)
+			return
+		}
+
+		info, err := fs.Stat(root, name)
+		if errors.Is(err, fs.ErrNotExist) && opts.SPAFallback {
+			name = "index.html"
+			info, err = fs.Stat(root, name)
+		}
+		if err != nil {
+			fsError(store, err)
+			return
+		}
+
+		if info.IsDir() {
+			if !strings.HasSuffix(store.R.URL.Path, "/") {
+				u := url.URL{Path: store.R.URL.Path + "/", RawQuery: store.R.URL.RawQuery}
+				store.Redirect(http.StatusMovedPermanently, u.String())
+				return
+			}
+			index := path.Join(name, "index.html")
+			if indexInfo, err := fs.Stat(root, index); err == nil && !indexInfo.IsDir() {
+				name, info = index, indexInfo
+			} else if opts.Browse {
+				serveDirList(store, root, name)
+				return
+			} else {
+				store.Error404("404 not found")
+				return
+			}
+		}
+
+		if opts.Precompressed {
+			store.W.Header().Add("Vary", "Accept-Encoding")
+			for _, p := range precompressedExts {
+				if !acceptEncoding(store.R.Header.Get("Accept-Encoding"), p.encoding) {
+					continue
+				}
+				if cInfo, err := fs.Stat(root, name+p.ext); err == nil && !cInfo.IsDir() {
+					ctype := mime.TypeByExtension(path.Ext(name))
+					if ctype == "" {
+						ctype = "application/octet-stream"
+					}
+					store.W.Header().Set("Content-Type", ctype)
+					store.W.Header().Set("Content-Encoding", p.encoding)
+					serveFile(store, root, name+p.ext, cInfo, etags)
+					return
+				}
+			}
+		}
+		serveFile(store, root, name, info, etags)
+	}
+}
+
+// cleanFilePath converts url path to a valid fs.FS path, and reports false for traversal attempts.
+func cleanFilePath(rawPath string) (name string, ok bool) {
+	if strings.IndexByte(rawPath, '\\') >= 0 || strings.IndexByte(rawPath, 0) >= 0 {
+		return "", false
+	}
+	for _, elem := range strings.Split(rawPath, "/") {
+		if elem == ".." {
+			return "", false
+		}
+	}
+	if name = path.Clean("/" + rawPath)[1:]; name == "" {
+		name = "."
+	}
+	return name, true
+}
+
+func fsError(store *Store, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		store.Error404("404 not found")
+	} else if errors.Is(err, fs.ErrPermission) {
+		http.Error(store.W, "403 forbidden", http.StatusForbidden)
+	} else {
+		store.Error500("500 internal server error")
+	}
+}
+
+func serveFile(store *Store, root fs.FS, name string, info fs.FileInfo, etags *sync.Map) {
+	f, err := root.Open(name)
+	if err != nil {
+		fsError(store, err)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			fsError(store, err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	if store.W.Header().Get("ETag") == "" {
+		etag, err := fileETag(name, info, content, etags)
+		if err != nil {
+			fsError(store, err)
+			return
+		}
+		store.W.Header().Set("ETag", etag)
+	}
+	http.ServeContent(store.W, store.R, info.Name(), info.ModTime(), content)
+}
+
+type etagKey struct {
+	name string
+	size int64
+}
+
+// fileETag builds ETag from modification time and size, or from content hash if modification time is unknown,
+// e.g. files of embed.FS, so that different versions of same size are distinguished.
+// Content hashes are cached in etags by name and size, as such files are not expected to change.
+func fileETag(name string, info fs.FileInfo, content io.ReadSeeker, etags *sync.Map) (string, error) {
+	if !info.ModTime().IsZero() {
+		return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(info.Size(), 36) + `"`, nil
+	}
+	key := etagKey{name, info.Size()}
+	if etag, ok := etags.Load(key); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16]) + `"`
+	etags.Store(key, etag)
+	return etag, nil
+}
+
+func serveDirList(store *Store, root fs.FS, name string) {
+	entries, err := fs.ReadDir(root, name)
+	if err != nil {
+		fsError(store, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		u := url.URL{Path: entryName}
+		buf.WriteString("<a href=\"" + html.EscapeString(u.String()) + "\">" + html.EscapeString(entryName) + "</a>\n")
+	}
+	buf.WriteString("</pre>\n")
+
+	store.W.Header().Set("Content-Type", "text/html; charset=utf-8")
+	store.Respond200(buf.Bytes())
+}
+
+// acceptEncoding reports whether the 'Accept-Encoding' header value accepts the encoding with non-zero qvalue.
+// Explicit coding takes precedence over '*'.
+func acceptEncoding(header string, encoding string) bool {
+	var wildcard bool
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, encoding) && coding != "*" {
+			continue
+		}
+		accepted := true
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				accepted = false
+			}
+		}
+		if coding != "*" {
+			return accepted
+		}
+		wildcard = accepted
+	}
+	return wildcard
+}
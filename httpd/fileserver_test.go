@@ -0,0 +1,165 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestCleanFilePath(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		ok    bool
+	}{
+		{"", ".", true},
+		{"/", ".", true},
+		{"a/b.txt", "a/b.txt", true},
+		{"/a//b/./c/", "a/b/c", true},
+		{"../etc/passwd", "", false},
+		{"/a/../../etc/passwd", "", false},
+		{"/a/..", "", false},
+		{"..\\etc\\passwd", "", false},
+		{"/a\\..\\b", "", false},
+		{"/a\x00b", "", false},
+		{"/a/..b", "a/..b", true},
+	}
+	for _, tt := range tests {
+		if name, ok := cleanFilePath(tt.input); name != tt.name || ok != tt.ok {
+			t.Fatalf("cleanFilePath(%q) = %q %v, want %q %v", tt.input, name, ok, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		encoding string
+		want     bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"deflate, gzip;q=1.0, *;q=0.5", "gzip", true},
+		{"br;q=0, gzip", "br", false},
+		{"*", "br", true},
+		{"*;q=0, gzip", "gzip", true},
+		{"*;q=0, gzip", "br", false},
+		{"GZIP", "gzip", true},
+	}
+	for _, tt := range tests {
+		if got := acceptEncoding(tt.header, tt.encoding); got != tt.want {
+			t.Fatalf("acceptEncoding(%q, %q) = %v, want %v", tt.header, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	modTime := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":       {Data: []byte("root index"), ModTime: modTime},
+		"app.js":           {Data: []byte("console.log(1)"), ModTime: modTime},
+		"app.js.gz":        {Data: []byte("gzip content"), ModTime: modTime},
+		"app.js.br":        {Data: []byte("br content"), ModTime: modTime},
+		"docs/index.html":  {Data: []byte("docs index"), ModTime: modTime},
+		"files/a.txt":      {Data: []byte("0123456789"), ModTime: modTime},
+		"files/<b>.txt":    {Data: []byte("b"), ModTime: modTime},
+		"files/sub/c.txt":  {Data: []byte("c"), ModTime: modTime},
+		"secret/data.json": {Data: []byte("{}"), ModTime: modTime},
+	}
+
+	tests := []struct {
+		opts   FileServerOptions
+		method string
+		url    string
+		header http.Header
+		code   int
+		body   string
+		check  http.Header
+	}{
+		{FileServerOptions{}, http.MethodGet, "/static/files/a.txt", nil, 200, "0123456789", http.Header{"Content-Type": {"text/plain; charset=utf-8"}}},
+		{FileServerOptions{}, http.MethodGet, "/static/files/a.txt", http.Header{"Range": {"bytes=2-4"}}, 206, "234", http.Header{"Content-Range": {"bytes 2-4/10"}}},
+		{FileServerOptions{}, http.MethodGet, "/static/files/a.txt", http.Header{"If-None-Match": {`"` + strconv.FormatInt(modTime.UnixNano(), 36) + "-a" + `"`}}, 304, "", nil},
+		{FileServerOptions{}, http.MethodGet, "/static/files/a.txt", http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}}, 304, "", nil},
+		{FileServerOptions{}, http.MethodPost, "/static/files/a.txt", nil, 405, "405 method not allowed\n", http.Header{"Allow": {"GET, HEAD"}}},
+		{FileServerOptions{}, http.MethodGet, "/static/files/missing.txt", nil, 404, "404 not found\n", nil},
+		{FileServerOptions{}, http.MethodGet, "/static/files/..%5C..%5Csecret/data.json", nil, 400, "400 invalid url path\n", nil},
+		{FileServerOptions{}, http.MethodGet, "/static/docs", nil, 301, "<a href=\"/static/docs/\">Moved Permanently</a>.\n\n", http.Header{"Location": {"/static/docs/"}}},
+		{FileServerOptions{}, http.MethodGet, "/static/docs/", nil, 200, "docs index", nil},
+		{FileServerOptions{}, http.MethodGet, "/static/files/", nil, 404, "404 not found\n", nil},
+		{FileServerOptions{Browse: true}, http.MethodGet, "/static/files/", nil, 200, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n<a href=\"%3Cb%3E.txt\">&lt;b&gt;.txt</a>\n<a href=\"a.txt\">a.txt</a>\n<a href=\"sub/\">sub/</a>\n</pre>\n", nil},
+		{FileServerOptions{SPAFallback: true}, http.MethodGet, "/static/some/page", nil, 200, "root index", nil},
+		{FileServerOptions{Precompressed: true}, http.MethodGet, "/static/app.js", http.Header{"Accept-Encoding": {"gzip, br"}}, 200, "br content", http.Header{"Content-Encoding": {"br"}, "Content-Type": {"text/javascript; charset=utf-8"}, "Vary": {"Accept-Encoding"}}},
+		{FileServerOptions{Precompressed: true}, http.MethodGet, "/static/app.js", http.Header{"Accept-Encoding": {"gzip"}}, 200, "gzip content", http.Header{"Content-Encoding": {"gzip"}}},
+		{FileServerOptions{Precompressed: true}, http.MethodGet, "/static/app.js", nil, 200, "console.log(1)", http.Header{"Content-Encoding": nil, "Vary": {"Accept-Encoding"}}},
+		{FileServerOptions{}, http.MethodHead, "/static/files/a.txt", nil, 200, "", http.Header{"Content-Length": {"10"}}},
+	}
+	for _, tt := range tests {
+		mux := NewMux()
+		mux.Handle("/static/*", MethodAll, FileServer(fsys, tt.opts))
+
+		r := httptest.NewRequest(tt.method, tt.url, nil)
+		for k, v := range tt.header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Fatalf("%s %s return %d %q, want %d %q", tt.method, tt.url, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		for k, v := range tt.check {
+			if got := w.Header().Get(k); (v == nil && got != "") || (v != nil && got != v[0]) {
+				t.Fatalf("%s %s return header %s: %q, want %q", tt.method, tt.url, k, got, v)
+			}
+		}
+	}
+}
+
+func TestFileServerETagWithoutModTime(t *testing.T) {
+	etagOf := func(fsys fstest.MapFS, header http.Header) (int, string) {
+		mux := NewMux()
+		mux.Handle("/*", http.MethodGet, FileServer(fsys, FileServerOptions{}))
+		r := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code, w.Header().Get("ETag")
+	}
+	oldBuild := fstest.MapFS{"app.js": {Data: []byte("v1")}} // zero ModTime like embed.FS
+	newBuild := fstest.MapFS{"app.js": {Data: []byte("v2")}}
+
+	_, oldETag := etagOf(oldBuild, http.Header{})
+	_, newETag := etagOf(newBuild, http.Header{})
+	if oldETag == "" || oldETag == newETag {
+		t.Fatalf("ETag of different content with same size = %q and %q, want different", oldETag, newETag)
+	}
+	if code, _ := etagOf(newBuild, http.Header{"If-None-Match": {oldETag}}); code != 200 {
+		t.Fatalf("If-None-Match with old ETag return %d, want 200", code)
+	}
+	if code, _ := etagOf(newBuild, http.Header{"If-None-Match": {newETag}}); code != 304 {
+		t.Fatalf("If-None-Match with current ETag return %d, want 304", code)
+	}
+}
+
+func TestFileServerETagCache(t *testing.T) {
+	fsys := fstest.MapFS{"app.js": {Data: []byte("v1")}}
+	mux := NewMux()
+	mux.Handle("/*", http.MethodGet, FileServer(fsys, FileServerOptions{}))
+	etagOf := func() string {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/app.js", nil))
+		return w.Header().Get("ETag")
+	}
+
+	first := etagOf()
+	fsys["app.js"].Data = []byte("v2") // hash is not computed again for same name and size
+	if got := etagOf(); got != first {
+		t.Fatalf("ETag of same name and size = %q, want cached %q", got, first)
+	}
+	fsys["app.js"].Data = []byte("v10")
+	if got := etagOf(); got == first {
+		t.Fatalf("ETag of different size = %q, want different from %q", got, first)
+	}
+}
@@ -0,0 +1,66 @@
+package httpd
+
+import "strings"
+
+// Group registers routes with a shared path prefix and group-scoped middlewares.
+// The middleware chain of routes in a group is the global chain, followed by the
+// middlewares of parent groups, followed by the middlewares of the group itself.
+type Group struct {
+	mux    *Mux
+	parent *Group
+	prefix string
+
+	middlewares []HandlerFunc
+	chain       []HandlerFunc
+	children    []*Group
+}
+
+// Group creates a route group with the given path prefix and middlewares.
+func (mux *Mux) Group(prefix string, middleware ...HandlerFunc) *Group {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	g := &Group{mux: mux, prefix: strings.TrimRight(prefix, "/"), middlewares: middleware}
+	g.rebuild()
+	mux.groups = append(mux.groups, g)
+	return g
+}
+
+// Group creates a nested route group with the given path prefix and middlewares.
+func (g *Group) Group(prefix string, middleware ...HandlerFunc) *Group {
+	g.mux.mu.Lock()
+	defer g.mux.mu.Unlock()
+
+	child := &Group{mux: g.mux, parent: g, prefix: g.prefix + strings.TrimRight(prefix, "/"), middlewares: middleware}
+	child.rebuild()
+	g.children = append(g.children, child)
+	return child
+}
+
+// Handle registers the handler for the given routePath and method. The routePath is relative to the group prefix.
+func (g *Group) Handle(path string, method string, handler HandlerFunc) *RouteInfo {
+	return g.mux.handle(g.prefix+path, method, handler, &g.chain)
+}
+
+// HandleMiddleware appends middlewares to the group, including routes that have been registered.
+func (g *Group) HandleMiddleware(middleware ...HandlerFunc) {
+	g.mux.mu.Lock()
+	defer g.mux.mu.Unlock()
+
+	g.middlewares = append(g.middlewares, middleware...)
+	g.rebuild()
+}
+
+// rebuild refreshes the chain of group and its nested groups.
+// Registered routes keep a pointer to the chain, so they will see the new one.
+func (g *Group) rebuild() {
+	parent := g.mux.middlewares
+	if g.parent != nil {
+		parent = g.parent.chain
+	}
+	chain := make([]HandlerFunc, 0, len(parent)+len(g.middlewares))
+	g.chain = append(append(chain, parent...), g.middlewares...)
+	for _, child := range g.children {
+		child.rebuild()
+	}
+}
@@ -0,0 +1,75 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func markMiddleware(mark string) httpd.HandlerFunc {
+	return func(s *httpd.Store) {
+		s.W.Write([]byte(mark + "_st "))
+		s.Next()
+		s.W.Write([]byte(mark + "_ed "))
+	}
+}
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		url    string
+		method string
+		want   string
+	}{
+		{"/ping", http.MethodGet, "g_st ping g_ed "},
+		{"/api/users", http.MethodGet, "g_st api_st users api_ed g_ed "},
+		{"/api/admin/users", http.MethodPost, "g_st api_st admin_st late_st admin_users late_ed admin_ed api_ed g_ed "},
+		{"/api/admin", http.MethodGet, "g_st api_st admin_st late_st admin_root late_ed admin_ed api_ed g_ed "},
+		{"/api/admin/users", http.MethodGet, "g_st api_st admin_st late_st 405 method not allowed\nlate_ed admin_ed api_ed g_ed "},
+		{"/api/admin/posts", http.MethodGet, "g_st 404 not found\ng_ed "},
+	}
+
+	mux := httpd.NewMux()
+	api := mux.Group("/api/", markMiddleware("api"))
+	admin := api.Group("/admin", markMiddleware("admin"))
+
+	mux.Handle("/ping", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("ping ")) })
+	api.Handle("/users", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("users ")) })
+	admin.Handle("/users", http.MethodPost, func(s *httpd.Store) { s.W.Write([]byte("admin_users ")) })
+	admin.Handle("/", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("admin_root ")) })
+
+	// middlewares registered later should also apply to registered routes
+	admin.HandleMiddleware(markMiddleware("late"))
+	mux.HandleMiddleware(markMiddleware("g"))
+
+	for _, tt := range tests {
+		u, err := url.ParseRequestURI(tt.url)
+		if err != nil {
+			t.Fatalf("ParseRequestURI: %v", err)
+		}
+
+		w := &fakeResponseWriter{header: make(http.Header)}
+		mux.ServeHTTP(w, &http.Request{Method: tt.method, URL: u})
+		if w.buf.String() != tt.want {
+			t.Fatalf("ServeHTTP(%q %q) return %q, want %q", tt.method, tt.url, w.buf.String(), tt.want)
+		}
+	}
+}
+
+func TestGroupRouteInfo(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(markMiddleware("g"))
+	admin := mux.Group("/admin", markMiddleware("admin"))
+	admin.Handle("/users/:id", http.MethodGet, func(s *httpd.Store) {
+		if s.I.Path != "/admin/users/:id" || len(*s.I.Middlewares) != 2 {
+			t.Fatalf("RouteInfo = %q with %d middlewares, want %q with %d", s.I.Path, len(*s.I.Middlewares), "/admin/users/:id", 2)
+		}
+		if id := s.RouteParam("id"); id != "10" {
+			t.Fatalf("RouteParam(%q) = %q, want %q", "id", id, "10")
+		}
+	})
+
+	u, _ := url.ParseRequestURI("/admin/users/10")
+	mux.ServeHTTP(&fakeResponseWriter{header: make(http.Header)}, &http.Request{Method: http.MethodGet, URL: u})
+}
@@ -4,6 +4,7 @@ package httpd
 import (
 	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
 const MethodAll string = "*"
@@ -21,6 +22,19 @@ var methodTagMap = map[string]string{
 	MethodAll:          "/*",
 }
 
+// methodList is the order of methods in 'Allow' header.
+var methodList = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
 type Mux struct {
 	mu   sync.RWMutex
 	root *treeNode
@@ -29,20 +43,30 @@ type Mux struct {
 	storePool sync.Pool
 
 	middlewares   []HandlerFunc
+	groups        []*Group
+	routes        []*RouteInfo
 	routeNotFound *RouteInfo
+	routeNotAllow *RouteInfo
+	routeOptions  *RouteInfo
+	routeError    func(*Store, error)
+	fallbacks     map[*[]HandlerFunc]*fallbackRoutes // 405 and `OPTIONS` routes with middlewares of groups
+
+	trustedProxies atomic.Pointer[trustedProxies]
 }
 
 // NewMux allocates and returns a new Mux.
 func NewMux() *Mux {
-	mux := &Mux{root: new(treeNode)}
+	mux := &Mux{root: new(treeNode), fallbacks: make(map[*[]HandlerFunc]*fallbackRoutes)}
 	mux.storePool.New = mux.newStore
 	mux.HandleNoRoute(func(store *Store) { store.Error404("404 not found") })
+	mux.HandleMethodNotAllowed(func(store *Store) { store.Error405("405 method not allowed") })
+	mux.HandleOptions(func(store *Store) { store.W.WriteHeader(http.StatusNoContent) })
 	return mux
 }
 
 func (mux *Mux) newStore() any {
 	params := Params{V: make([]string, 0, mux.maxParams)}
-	return &Store{W: &ResponseWriter{}, P: &params}
+	return &Store{W: &ResponseWriter{}, P: &params, mux: mux}
 }
 
 // ServeHTTP dispatches the request to the matched handler.
@@ -53,36 +77,107 @@ func (mux *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	store.I = mux.routeNotFound
 	store.mwIndex = -1
 
-	if info := findRoute(mux.root, r.URL.Path, r.Method, store.P); info != nil {
+	if info, allow, chain := findRoute(mux.root, r.URL.Path, r.Method, store.P); info != nil {
 		store.I = info
+		store.W.discard = r.Method == http.MethodHead && info.Method == http.MethodGet
+	} else if allow != "" {
+		routes := &fallbackRoutes{mux.routeNotAllow, mux.routeOptions}
+		if fb, ok := mux.fallbacks[chain]; ok {
+			routes = fb // run middlewares of group, e.g. CORS for preflight requests
+		}
+		store.I = routes.notAllow
+		if r.Method == http.MethodOptions {
+			store.I = routes.options
+		}
+		w.Header().Set("Allow", allow)
 	}
-	store.Next()
+	store.serve()
 
 	store.W.Origin = nil
 	store.W.Status = 0
+	store.W.discard = false
+	store.W.beforeHeader = nil
 	store.R = nil
 	store.I = nil
+	store.resetValues()
+	store.session = nil
+	store.csrfToken = nil
+	store.aborted = false
+	clear(store.afterResponse)
+	store.afterResponse = store.afterResponse[:0]
 	store.P.V = store.P.V[:0]
 	mux.storePool.Put(store)
 }
 
 // Handle registers the handler for the given routePath and method.
-func (mux *Mux) Handle(path string, method string, handler HandlerFunc) {
+// The returned RouteInfo can be named for reverse URL generation, e.g. `mux.Handle(path, method, handler).Name = "name"`.
+func (mux *Mux) Handle(path string, method string, handler HandlerFunc) *RouteInfo {
+	return mux.handle(path, method, handler, &mux.middlewares)
+}
+
+func (mux *Mux) handle(path string, method string, handler HandlerFunc, middlewares *[]HandlerFunc) *RouteInfo {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
-	info := newRouteInfo(path, method, handler, &mux.middlewares)
+	info := newRouteInfo(path, method, handler, middlewares)
 	paramsCnt, err := parseRoute(mux.root, path, method, info)
 	if err != nil {
 		panic(err)
 	}
 	mux.maxParams = max(mux.maxParams, paramsCnt)
+	mux.routes = append(mux.routes, info)
+	if _, ok := mux.fallbacks[middlewares]; !ok && middlewares != &mux.middlewares {
+		mux.fallbacks[middlewares] = &fallbackRoutes{
+			notAllow: newRouteInfo("", MethodAll, mux.routeNotAllow.HandlerFunc, middlewares),
+			options:  newRouteInfo("", http.MethodOptions, mux.routeOptions.HandlerFunc, middlewares),
+		}
+	}
+	return info
 }
 
+// fallbackRoutes are the 405 and `OPTIONS` routes that run the middlewares of matched path.
+type fallbackRoutes struct {
+	notAllow *RouteInfo
+	options  *RouteInfo
+}
+
+// HandleMiddleware appends global middlewares, which apply to all routes including those in groups.
 func (mux *Mux) HandleMiddleware(middleware ...HandlerFunc) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
 	mux.middlewares = append(mux.middlewares, middleware...)
+	for _, g := range mux.groups {
+		g.rebuild()
+	}
 }
 
 func (mux *Mux) HandleNoRoute(handler HandlerFunc) {
 	mux.routeNotFound = newRouteInfo("", MethodAll, handler, &mux.middlewares)
 }
+
+// HandleOptions registers the handler for `OPTIONS` requests whose path is matched but has no explicit `OPTIONS` route.
+// The 'Allow' response header has been set with allowed methods of matched path before handler is called.
+// The middlewares of the first route registered on matched path are applied, including those of its group.
+func (mux *Mux) HandleOptions(handler HandlerFunc) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.routeOptions = newRouteInfo("", http.MethodOptions, handler, &mux.middlewares)
+	for chain, fb := range mux.fallbacks {
+		fb.options = newRouteInfo("", http.MethodOptions, handler, chain)
+	}
+}
+
+// HandleMethodNotAllowed registers the handler for requests whose path is matched but method is not.
+// The 'Allow' response header has been set with allowed methods of matched path before handler is called.
+// The middlewares of the first route registered on matched path are applied, including those of its group.
+func (mux *Mux) HandleMethodNotAllowed(handler HandlerFunc) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.routeNotAllow = newRouteInfo("", MethodAll, handler, &mux.middlewares)
+	for chain, fb := range mux.fallbacks {
+		fb.notAllow = newRouteInfo("", MethodAll, handler, chain)
+	}
+}
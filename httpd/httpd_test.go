@@ -18,6 +18,8 @@ func TestHandlePanic(t *testing.T) {
 		{"duplicatedParam", "/bbb/:id/:id", http.MethodGet},
 		{"invalidPath", "/ccc/:/", http.MethodGet},
 		{"invalidMethod", "/ddd", "GETT"},
+		{"invalidConstraint", "/eee/:id<int", http.MethodGet},
+		{"invalidRegexp", "/fff/:id<[a-z>", http.MethodGet},
 	}
 
 	mux := httpd.NewMux()
@@ -114,6 +116,94 @@ func TestHandleNoRoute(t *testing.T) {
 	}
 }
 
+func TestHandleMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		url    string
+		method string
+		code   int
+		allow  string
+	}{
+		{"/", http.MethodPost, 405, "GET, HEAD, OPTIONS"},
+		{"/aaa", http.MethodGet, 405, "POST, PUT, DELETE, OPTIONS"},
+		{"/aaa", http.MethodPost, 200, ""},
+		{"/bbb/10", http.MethodGet, 405, "PATCH, OPTIONS"},
+		{"/ccc", http.MethodGet, 404, ""},
+		{"/ddd", http.MethodGet, 200, ""},
+	}
+
+	mark := "TestHandleMethodNotAllowed"
+
+	mux := httpd.NewMux()
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) {})
+	mux.Handle("/aaa", http.MethodDelete, func(s *httpd.Store) {})
+	mux.Handle("/aaa", http.MethodPost, func(s *httpd.Store) {})
+	mux.Handle("/aaa", http.MethodPut, func(s *httpd.Store) {})
+	mux.Handle("/bbb/:id", http.MethodPatch, func(s *httpd.Store) {})
+	mux.Handle("/ccc/ddd", http.MethodGet, func(s *httpd.Store) {})
+	mux.Handle("/ddd", httpd.MethodAll, func(s *httpd.Store) {})
+	mux.HandleMethodNotAllowed(func(s *httpd.Store) {
+		s.W.WriteHeader(405)
+		s.W.Write([]byte(mark))
+	})
+	for _, tt := range tests {
+		u, err := url.ParseRequestURI(tt.url)
+		if err != nil {
+			t.Fatalf("ParseRequestURI: %v", err)
+		}
+
+		w := &fakeResponseWriter{header: make(http.Header)}
+		mux.ServeHTTP(w, &http.Request{Method: tt.method, URL: u})
+		if code := max(w.code, 200); code != tt.code || w.header.Get("Allow") != tt.allow {
+			t.Fatalf("ServeHTTP(%q %q) return %d %q, want %d %q", tt.method, tt.url, code, w.header.Get("Allow"), tt.code, tt.allow)
+		}
+		if tt.code == 405 && w.buf.String() != mark {
+			t.Fatalf("ServeHTTP(%q %q) return %q, want %q", tt.method, tt.url, w.buf.String(), mark)
+		}
+	}
+}
+
+func TestHandleHeadAndOptions(t *testing.T) {
+	tests := []struct {
+		url    string
+		method string
+		code   int
+		allow  string
+		mark   string
+	}{
+		{"/aaa", http.MethodHead, 200, "", ""},
+		{"/aaa", http.MethodGet, 200, "", "get_aaa"},
+		{"/aaa", http.MethodOptions, 204, "GET, HEAD, OPTIONS", ""},
+		{"/bbb", http.MethodHead, 200, "", "head_bbb"},
+		{"/bbb", http.MethodOptions, 200, "", "options_bbb"},
+		{"/ccc", http.MethodHead, 405, "POST, OPTIONS", "405 method not allowed\n"},
+		{"/ccc", http.MethodOptions, 204, "POST, OPTIONS", ""},
+		{"/ddd", http.MethodHead, 200, "", ""},
+		{"/ddd", http.MethodOptions, 200, "", "any_ddd"},
+		{"/eee", http.MethodOptions, 404, "", "404 not found\n"},
+	}
+
+	mux := httpd.NewMux()
+	mux.Handle("/aaa", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("get_aaa")) })
+	mux.Handle("/bbb", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("get_bbb")) })
+	mux.Handle("/bbb", http.MethodHead, func(s *httpd.Store) { s.W.Write([]byte("head_bbb")) })
+	mux.Handle("/bbb", http.MethodOptions, func(s *httpd.Store) { s.W.Write([]byte("options_bbb")) })
+	mux.Handle("/ccc", http.MethodPost, func(s *httpd.Store) { s.W.Write([]byte("post_ccc")) })
+	mux.Handle("/ddd", http.MethodGet, func(s *httpd.Store) { s.W.Write([]byte("get_ddd")) })
+	mux.Handle("/ddd", httpd.MethodAll, func(s *httpd.Store) { s.W.Write([]byte("any_ddd")) })
+	for _, tt := range tests {
+		u, err := url.ParseRequestURI(tt.url)
+		if err != nil {
+			t.Fatalf("ParseRequestURI: %v", err)
+		}
+
+		w := &fakeResponseWriter{header: make(http.Header)}
+		mux.ServeHTTP(w, &http.Request{Method: tt.method, URL: u})
+		if w.code != tt.code || w.header.Get("Allow") != tt.allow || w.buf.String() != tt.mark {
+			t.Fatalf("ServeHTTP(%q %q) return %d %q %q, want %d %q %q", tt.method, tt.url, w.code, w.header.Get("Allow"), w.buf.String(), tt.code, tt.allow, tt.mark)
+		}
+	}
+}
+
 func routeInfoStr(r httpd.RouteInfo) string { return r.Path + r.Method + r.HandlerName }
 func testRouteInfo0(s *httpd.Store)         { s.W.Write([]byte(routeInfoStr(*s.I))) }
 func testRouteInfo1(s *httpd.Store)         { s.W.Write([]byte(routeInfoStr(*s.I))) }
@@ -124,12 +214,12 @@ func testRouteInfo5(s *httpd.Store)         { s.W.Write([]byte(routeInfoStr(*s.I
 
 func TestRouteInfo(t *testing.T) {
 	routes := []httpd.RouteInfo{
-		{"", httpd.MethodAll, "github.com/whoisnian/glb/httpd_test.testRouteInfo0", testRouteInfo0, &[]httpd.HandlerFunc{}},
-		{"/aaa", http.MethodGet, "github.com/whoisnian/glb/httpd_test.testRouteInfo1", testRouteInfo1, &[]httpd.HandlerFunc{}},
-		{"/aaa", httpd.MethodAll, "github.com/whoisnian/glb/httpd_test.testRouteInfo2", testRouteInfo2, &[]httpd.HandlerFunc{}},
-		{"/bbb/:id", http.MethodPost, "github.com/whoisnian/glb/httpd_test.testRouteInfo3", testRouteInfo3, &[]httpd.HandlerFunc{}},
-		{"/ccc/*", http.MethodPut, "github.com/whoisnian/glb/httpd_test.testRouteInfo4", testRouteInfo4, &[]httpd.HandlerFunc{}},
-		{"/ccc/ddd", http.MethodPut, "github.com/whoisnian/glb/httpd_test.testRouteInfo5", testRouteInfo5, &[]httpd.HandlerFunc{}},
+		{"", httpd.MethodAll, "", "github.com/whoisnian/glb/httpd_test.testRouteInfo0", testRouteInfo0, &[]httpd.HandlerFunc{}},
+		{"/aaa", http.MethodGet, "", "github.com/whoisnian/glb/httpd_test.testRouteInfo1", testRouteInfo1, &[]httpd.HandlerFunc{}},
+		{"/aaa", httpd.MethodAll, "", "github.com/whoisnian/glb/httpd_test.testRouteInfo2", testRouteInfo2, &[]httpd.HandlerFunc{}},
+		{"/bbb/:id", http.MethodPost, "", "github.com/whoisnian/glb/httpd_test.testRouteInfo3", testRouteInfo3, &[]httpd.HandlerFunc{}},
+		{"/ccc/*", http.MethodPut, "", "github.com/whoisnian/glb/httpd_test.testRouteInfo4", testRouteInfo4, &[]httpd.HandlerFunc{}},
+		{"/ccc/ddd", http.MethodPut, "", "github.com/whoisnian/glb/httpd_test.testRouteInfo5", testRouteInfo5, &[]httpd.HandlerFunc{}},
 	}
 	tests := []struct {
 		url    string
@@ -188,7 +278,7 @@ func TestServeHTTP(t *testing.T) {
 	}{
 		{"/", http.MethodGet, 404, "404 not found\n"},
 		{"/aaa", http.MethodGet, 200, "get_aaa"},
-		{"/aaa", http.MethodPost, 404, "404 not found\n"},
+		{"/aaa", http.MethodPost, 405, "405 method not allowed\n"},
 		{"/aaa/", http.MethodGet, 404, "404 not found\n"},
 		{"/bbb", http.MethodPost, 404, "404 not found\n"},
 		{"/bbb/", http.MethodPost, 200, "post_bbb"},
@@ -0,0 +1,129 @@
+package httpd
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/whoisnian/glb/util/netutil"
+)
+
+// IPAccess checks client IP against allow and deny lists, which can be reloaded at runtime.
+// In-flight requests keep using the lists they started with, and new requests use the reloaded lists.
+type IPAccess struct {
+	rules    atomic.Pointer[ipAccessRules]
+	onDenied HandlerFunc
+}
+
+type ipAccessRules struct {
+	allow *netutil.IPFilter // nil means allowing all
+	deny  *netutil.IPFilter
+}
+
+// NewIPAccess creates IPAccess with lists of IPs or CIDRs. Deny takes precedence over allow,
+// and empty allow list means allowing all IPs that are not denied. Denied requests are handled by onDenied,
+// which replies 403 with plain text if nil.
+func NewIPAccess(allow, deny []string, onDenied HandlerFunc) (*IPAccess, error) {
+	if onDenied == nil {
+		onDenied = func(store *Store) { http.Error(store.W, "403 forbidden", http.StatusForbidden) }
+	}
+	access := &IPAccess{onDenied: onDenied}
+	if err := access.Reload(allow, deny); err != nil {
+		return nil, err
+	}
+	return access, nil
+}
+
+// Reload replaces lists atomically. The old lists are kept if any IP or CIDR is invalid.
+func (access *IPAccess) Reload(allow, deny []string) error {
+	rules := &ipAccessRules{deny: netutil.NewIPFilter()}
+	if len(allow) > 0 {
+		rules.allow = netutil.NewIPFilter()
+		if err := addIPOrCIDRs(rules.allow, allow); err != nil {
+			return err
+		}
+	}
+	if err := addIPOrCIDRs(rules.deny, deny); err != nil {
+		return err
+	}
+	access.rules.Store(rules)
+	return nil
+}
+
+// ReloadFile reads lists from file and replaces them atomically. Each line of the file is
+// an "allow" or "deny" keyword followed by an IP or CIDR, and lines starting with '#' are ignored, e.g.
+//
+//	# office network
+//	allow 192.168.0.0/16
+//	deny  192.168.100.1
+func (access *IPAccess) ReloadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var allow, deny []string
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return errors.New("httpd: invalid ip access rule at " + name + ":" + strconv.Itoa(lineNum))
+		}
+		switch fields[0] {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return errors.New("httpd: invalid ip access rule at " + name + ":" + strconv.Itoa(lineNum))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return access.Reload(allow, deny)
+}
+
+// Allowed reports whether ip is allowed by current lists.
+func (access *IPAccess) Allowed(ip net.IP) bool {
+	rules := access.rules.Load()
+	if ip == nil || rules.deny.Contains(ip) {
+		return false
+	}
+	return rules.allow == nil || rules.allow.Contains(ip)
+}
+
+// Middleware returns a middleware that checks Store.GetClientIP() with IPAccess.
+// Use Mux.SetTrustedProxies() to avoid IP spoofing by forwarding headers.
+func (access *IPAccess) Middleware() HandlerFunc {
+	return func(store *Store) {
+		if access.Allowed(net.ParseIP(store.GetClientIP())) {
+			store.Next()
+		} else {
+			access.onDenied(store)
+		}
+	}
+}
+
+func addIPOrCIDRs(filter *netutil.IPFilter, list []string) error {
+	for _, s := range list {
+		cidr, err := netutil.ParseIPOrCIDR(s)
+		if err != nil {
+			return err
+		}
+		if err = filter.Add(cidr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
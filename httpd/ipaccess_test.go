@@ -0,0 +1,79 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestIPAccess(t *testing.T) {
+	if _, err := httpd.NewIPAccess([]string{"10.0.0.0/33"}, nil, nil); err == nil {
+		t.Fatalf("NewIPAccess() with invalid CIDR should fail")
+	}
+	access, err := httpd.NewIPAccess([]string{"10.0.0.0/8", "::1"}, []string{"10.0.0.2"}, nil)
+	if err != nil {
+		t.Fatalf("NewIPAccess() got error %v", err)
+	}
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(access.Middleware())
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte("ok")) })
+	check := func(remote string, code int) {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Fatalf("request from %s return %d, want %d", remote, w.Code, code)
+		}
+	}
+	check("10.0.0.1:1234", 200)
+	check("10.0.0.2:1234", 403)
+	check("[::1]:1234", 200)
+	check("8.8.8.8:1234", 403)
+	check("invalid", 403)
+
+	name := filepath.Join(t.TempDir(), "ip.rules")
+	os.WriteFile(name, []byte("# comment\n\ndeny 10.0.0.1\nallow 0.0.0.0/0\n"), 0644)
+	if err = access.ReloadFile(name); err != nil {
+		t.Fatalf("ReloadFile() got error %v", err)
+	}
+	check("10.0.0.1:1234", 403)
+	check("10.0.0.2:1234", 200)
+	check("8.8.8.8:1234", 200)
+
+	os.WriteFile(name, []byte("allow 10.0.0.1\npermit 8.8.8.8\n"), 0644)
+	if err = access.ReloadFile(name); err == nil {
+		t.Fatalf("ReloadFile() with invalid rule should fail")
+	}
+	check("8.8.8.8:1234", 200) // old lists are kept
+}
+
+func TestIPAccessReloadRace(t *testing.T) {
+	access, _ := httpd.NewIPAccess(nil, []string{"10.0.0.1"}, func(s *httpd.Store) { s.W.WriteHeader(http.StatusTeapot) })
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(access.Middleware())
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) {})
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 100 {
+			access.Reload(nil, []string{"10.0.0.1"})
+		}
+	})
+	for range 100 {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusTeapot {
+			t.Fatalf("request return %d, want %d", w.Code, http.StatusTeapot)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,223 @@
+package httpd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeoutOptions configures the Timeout middleware.
+type TimeoutOptions struct {
+	Timeout time.Duration
+	Status  int    // status code replied on timeout, default 503, usually 503 or 504
+	Message string // response body replied on timeout, default "503 service unavailable" for status 503
+}
+
+// Timeout returns a middleware that cancels the request context after Timeout. If the handler has not written
+// response header yet, Status and Message are replied immediately, and the later writes of handler fail with
+// http.ErrHandlerTimeout. Handlers should return soon after the request context is done.
+//
+// It can be attached to a group, or a single route by an anonymous group, e.g.
+//
+//	mux.Group("", httpd.Timeout(httpd.TimeoutOptions{Timeout: time.Second})).Handle(path, method, handler)
+func Timeout(opts TimeoutOptions) HandlerFunc {
+	if opts.Timeout <= 0 {
+		panic("httpd: timeout must be positive")
+	}
+	if opts.Status == 0 {
+		opts.Status = http.StatusServiceUnavailable
+	}
+	if opts.Message == "" {
+		opts.Message = strconv.Itoa(opts.Status) + " " + strings.ToLower(http.StatusText(opts.Status))
+	}
+
+	return func(store *Store) {
+		ctx, cancel := context.WithTimeout(store.R.Context(), opts.Timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{origin: store.W.Origin, header: store.W.Origin.Header().Clone(), ctx: ctx, opts: &opts}
+		defer context.AfterFunc(ctx, tw.timeout)()
+
+		store.W.Origin = tw
+		store.R = store.R.WithContext(ctx)
+		store.Next()
+		store.W.Origin = tw.origin
+		if tw.finish() {
+			store.W.Status = opts.Status
+		}
+	}
+}
+
+// timeoutWriter guards origin with a mutex, as the timeout reply is written by another goroutine.
+// Handler modifies its own header map, which is copied to origin when response header is written.
+type timeoutWriter struct {
+	mu     sync.Mutex
+	origin http.ResponseWriter
+	header http.Header
+	ctx    context.Context
+	opts   *TimeoutOptions
+
+	wroteHeader bool
+	timedOut    bool
+	done        bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	} else if tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timeoutLocked() // handler may write before timeout reply is scheduled
+		return
+	}
+	if code >= 200 {
+		tw.wroteHeader = true
+	}
+	dst := tw.origin.Header()
+	clear(dst)
+	maps.Copy(dst, tw.header)
+	tw.origin.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		if tw.writeHeaderLocked(http.StatusOK); tw.timedOut {
+			return 0, http.ErrHandlerTimeout
+		}
+	}
+	return tw.origin.Write(p)
+}
+
+// timeout replies error to client if deadline is exceeded and handler has not written response header.
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.done || tw.wroteHeader || tw.timedOut || tw.ctx.Err() != context.DeadlineExceeded {
+		return
+	}
+	tw.timeoutLocked()
+}
+
+func (tw *timeoutWriter) timeoutLocked() {
+	tw.timedOut = true
+	h := tw.origin.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	tw.origin.WriteHeader(tw.opts.Status)
+	io.WriteString(tw.origin, tw.opts.Message)
+	if flusher, ok := tw.origin.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish stops the later timeout reply, and returns whether the request has timed out.
+func (tw *timeoutWriter) finish() (timedOut bool) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader && !tw.timedOut && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timeoutLocked() // handler returns without writing after deadline
+	}
+	tw.done = true
+	return tw.timedOut
+}
+
+// Unwrap returns the original http.ResponseWriter.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.origin
+}
+
+// Flush implements the standard http.Flusher interface.
+func (tw *timeoutWriter) Flush() {
+	tw.FlushError()
+}
+
+// FlushError attempts to invoke FlushError() of the original http.ResponseWriter.
+func (tw *timeoutWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		if tw.writeHeaderLocked(http.StatusOK); tw.timedOut {
+			return http.ErrHandlerTimeout
+		}
+	}
+	if flusher, ok := tw.origin.(interface{ FlushError() error }); ok {
+		return flusher.FlushError()
+	} else if flusher, ok := tw.origin.(http.Flusher); ok {
+		flusher.Flush()
+		return nil
+	}
+	return http.ErrNotSupported
+}
+
+// Hijack implements the standard http.Hijacker interface. Hijacked connection is not affected by timeout.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	if hj, ok := tw.origin.(http.Hijacker); ok {
+		tw.wroteHeader = true
+		maps.Copy(tw.origin.Header(), tw.header)
+		return hj.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// MaxBodySize returns a middleware that limits request body to n bytes with http.MaxBytesReader.
+// Requests with larger 'Content-Length' are replied 413 immediately. If reading body exceeds the limit,
+// the handler gets *http.MaxBytesError, and 413 is replied if the handler has not written response.
+func MaxBodySize(n int64) HandlerFunc {
+	return func(store *Store) {
+		if store.R.ContentLength > n {
+			http.Error(store.W, "413 request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		body := &maxBytesBody{ReadCloser: http.MaxBytesReader(store.W, store.R.Body, n)}
+		store.R.Body = body
+		store.Next()
+		if body.exceeded && store.W.Status == 0 {
+			http.Error(store.W, "413 request entity too large", http.StatusRequestEntityTooLarge)
+		}
+	}
+}
+
+type maxBytesBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *maxBytesBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if err != nil && errors.As(err, &maxErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
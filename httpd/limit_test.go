@@ -0,0 +1,118 @@
+package httpd_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestTimeout(t *testing.T) {
+	var writeErr error
+	var status int
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(func(s *httpd.Store) {
+		s.W.Header().Set("X-Outer", "1")
+		s.Next()
+		status = s.W.Status
+	})
+	g := mux.Group("", httpd.Timeout(httpd.TimeoutOptions{Timeout: 20 * time.Millisecond, Status: http.StatusGatewayTimeout, Message: "timeout"}))
+	g.Handle("/slow", http.MethodGet, func(s *httpd.Store) {
+		<-s.R.Context().Done()
+		s.W.Header().Set("X-Inner", "1")
+		_, writeErr = s.W.Write([]byte("late"))
+	})
+	g.Handle("/fast", http.MethodGet, func(s *httpd.Store) {
+		s.W.Header().Set("X-Inner", "1")
+		s.Respond200([]byte("fast"))
+	})
+	g.Handle("/started", http.MethodGet, func(s *httpd.Store) {
+		s.W.WriteHeader(http.StatusAccepted)
+		<-s.R.Context().Done()
+		_, writeErr = s.W.Write([]byte("done"))
+	})
+	mux.Handle("/other", http.MethodGet, func(s *httpd.Store) {
+		if _, ok := s.R.Context().Deadline(); ok {
+			t.Errorf("route without Timeout should not have deadline")
+		}
+		s.Respond200(nil)
+	})
+
+	tests := []struct {
+		path    string
+		code    int
+		body    string
+		inner   string
+		wantErr error
+	}{
+		{"/slow", 504, "timeout", "", http.ErrHandlerTimeout},
+		{"/fast", 200, "fast", "1", nil},
+		{"/started", 202, "done", "", nil},
+		{"/other", 200, "", "", nil},
+	}
+	for _, tt := range tests {
+		writeErr, status = nil, 0
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body || status != tt.code {
+			t.Fatalf("GET %s return %d %q with status %d, want %d %q", tt.path, w.Code, w.Body.String(), status, tt.code, tt.body)
+		}
+		if w.Header().Get("X-Outer") != "1" || w.Header().Get("X-Inner") != tt.inner {
+			t.Fatalf("GET %s return header %v", tt.path, w.Header())
+		}
+		if !errors.Is(writeErr, tt.wantErr) {
+			t.Fatalf("GET %s handler write error %v, want %v", tt.path, writeErr, tt.wantErr)
+		}
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.MaxBodySize(8))
+	mux.Handle("/read", http.MethodPost, func(s *httpd.Store) {
+		if body, err := io.ReadAll(s.R.Body); err == nil {
+			s.Respond200(body)
+		}
+	})
+	mux.Handle("/custom", http.MethodPost, func(s *httpd.Store) {
+		if _, err := io.ReadAll(s.R.Body); err != nil {
+			http.Error(s.W, "bad request", http.StatusBadRequest)
+		}
+	})
+	mux.Handle("/bind", http.MethodPost, httpd.CreateErrorHandler(func(s *httpd.Store) error {
+		var v struct {
+			Name string `json:"name"`
+		}
+		return s.Bind(&v)
+	}))
+
+	tests := []struct {
+		path   string
+		body   string
+		length int64
+		code   int
+		resp   string
+	}{
+		{"/read", "12345678", 8, 200, "12345678"},
+		{"/read", "123456789", 9, 413, "413 request entity too large\n"},
+		{"/read", "123456789", -1, 413, "413 request entity too large\n"},
+		{"/custom", "123456789", -1, 400, "bad request\n"},
+		{"/bind", `{"a":1}`, -1, 200, ""},
+		{"/bind", `{"name":"123456789"}`, -1, 413, "413 request entity too large\n"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.path, io.NopCloser(strings.NewReader(tt.body)))
+		r.ContentLength = tt.length
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.resp {
+			t.Fatalf("POST %s with %q return %d %q, want %d %q", tt.path, tt.body, w.Code, w.Body.String(), tt.code, tt.resp)
+		}
+	}
+}
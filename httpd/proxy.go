@@ -0,0 +1,101 @@
+package httpd
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/whoisnian/glb/util/netutil"
+)
+
+// trustedProxies is the config of Mux.SetTrustedProxies().
+type trustedProxies struct {
+	header string
+	filter *netutil.IPFilter
+}
+
+// SetTrustedProxies sets the forwarding header written by proxies and IPs or CIDRs of trusted proxies, e.g. "10.0.0.0/8", "::1".
+// The header could be "Forwarded" (RFC 7239), "X-Forwarded-For", "X-Real-IP" or others with comma-separated IPs.
+// Once set, Store.GetClientIP() honors only the header and only if Request.RemoteAddr is trusted,
+// and skips trusted hops from right to left. Call it without proxies to trust no proxy.
+// The header must be the one set by proxies, otherwise clients may spoof IP by sending it themselves.
+// It's safe to call SetTrustedProxies while serving requests.
+func (mux *Mux) SetTrustedProxies(header string, proxies ...string) error {
+	if header == "" {
+		return errors.New("httpd: SetTrustedProxies() want non-empty forwarding header")
+	}
+	filter := netutil.NewIPFilter()
+	for _, proxy := range proxies {
+		cidr, err := netutil.ParseIPOrCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		if err = filter.Add(cidr); err != nil {
+			return err
+		}
+	}
+	mux.trustedProxies.Store(&trustedProxies{header: http.CanonicalHeaderKey(header), filter: filter})
+	return nil
+}
+
+// trustedClientIP looks for client IP from right to left in Request.RemoteAddr and then the configured forwarding header,
+// and stops at the first untrusted hop. If all hops are trusted, the leftmost one is returned.
+func trustedClientIP(r *http.Request, trusted *trustedProxies) string {
+	host, _ := netutil.SplitHostPort(r.RemoteAddr)
+	if !isTrustedHop(trusted.filter, host) {
+		return host
+	}
+
+	var hops []string
+	values := r.Header.Values(trusted.header)
+	if trusted.header == "Forwarded" {
+		hops = parseForwardedFor(values)
+	} else {
+		for _, value := range values {
+			for hop := range strings.SplitSeq(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		host = hops[i]
+		if !isTrustedHop(trusted.filter, host) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrustedHop(trusted *netutil.IPFilter, host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && trusted.Contains(ip)
+}
+
+// parseForwardedFor extracts the 'for' parameters of RFC 7239 'Forwarded' header in order, with quotes and ports removed.
+// Obfuscated identifiers like "unknown" or "_hidden" are kept as they are.
+func parseForwardedFor(values []string) (hops []string) {
+	for _, value := range values {
+		for element := range strings.SplitSeq(value, ",") {
+			for pair := range strings.SplitSeq(element, ";") {
+				key, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				node = strings.Trim(node, `"`)
+				if strings.HasPrefix(node, "[") { // "[2001:db8:cafe::17]:4711"
+					if i := strings.IndexByte(node, ']'); i > 0 {
+						node = node[1:i]
+					}
+				} else if strings.Count(node, ":") == 1 { // "192.0.2.43:47011"
+					node, _, _ = strings.Cut(node, ":")
+				}
+				hops = append(hops, node)
+			}
+		}
+	}
+	return hops
+}
@@ -0,0 +1,61 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestSetTrustedProxies(t *testing.T) {
+	var clientIP string
+	mux := httpd.NewMux()
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) { clientIP = s.GetClientIP() })
+	if err := mux.SetTrustedProxies("X-Forwarded-For", "10.0.0.0/8", "fd00::/8", "invalid"); err == nil {
+		t.Fatalf("SetTrustedProxies() with invalid proxy should fail")
+	}
+	if err := mux.SetTrustedProxies("", "10.0.0.0/8"); err == nil {
+		t.Fatalf("SetTrustedProxies() with empty header should fail")
+	}
+
+	tests := []struct {
+		proxyHeader string // empty for legacy mode before SetTrustedProxies
+		remote      string
+		header      http.Header
+		want        string
+	}{
+		{"", "1.1.1.1:1234", http.Header{"X-Forwarded-For": {"8.8.8.8"}}, "8.8.8.8"},
+		{"X-Forwarded-For", "1.1.1.1:1234", http.Header{"X-Forwarded-For": {"8.8.8.8"}}, "1.1.1.1"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Client-Ip": {"8.8.8.8"}}, "10.0.0.1"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"8.8.8.8"}}, "8.8.8.8"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8, 10.0.0.2"}}, "8.8.8.8"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6, 8.8.8.8", "10.0.0.2"}}, "8.8.8.8"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"X-Forwarded-For", "[fd00::1]:1234", http.Header{"X-Forwarded-For": {"2001:db8::1"}}, "2001:db8::1"},
+		{"X-Forwarded-For", "[2001:db8::2]:1234", http.Header{"X-Forwarded-For": {"2001:db8::1"}}, "2001:db8::2"},
+		{"x-real-ip", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"8.8.8.8"}}, "8.8.8.8"},
+		{"Forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {`for=192.0.2.60;proto=http;by=203.0.113.43`}}, "192.0.2.60"},
+		{"Forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711", For=10.0.0.5:80`}}, "2001:db8:cafe::17"},
+		{"Forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {`for=unknown`, `for=10.0.0.5`}}, "unknown"},
+		// headers other than the configured one are sent by client and must be ignored
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.9"}, "Forwarded": {"for=1.2.3.4"}}, "203.0.113.9"},
+		{"X-Forwarded-For", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"1.2.3.4"}}, "10.0.0.1"},
+		{"X-Real-IP", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"203.0.113.9"}, "X-Forwarded-For": {"1.2.3.4"}}, "203.0.113.9"},
+		{"Forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {"for=203.0.113.9"}, "X-Forwarded-For": {"1.2.3.4"}}, "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		if tt.proxyHeader != "" {
+			if err := mux.SetTrustedProxies(tt.proxyHeader, "10.0.0.0/8", "fd00::/8"); err != nil {
+				t.Fatalf("SetTrustedProxies() got error %v", err)
+			}
+		}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header = tt.header
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+		if clientIP != tt.want {
+			t.Fatalf("GetClientIP() from %s with %v (trusted %s) = %q, want %q", tt.remote, tt.header, tt.proxyHeader, clientIP, tt.want)
+		}
+	}
+}
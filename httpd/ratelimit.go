@@ -0,0 +1,139 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitResult is the result of consuming one request from RateLimitStore.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // remaining requests that can be made immediately
+	Reset      time.Duration // time until all requests of the window are available again
+	RetryAfter time.Duration // time until the next request is allowed, zero if Allowed
+}
+
+// RateLimitStore stores the rate limit state of each key.
+// Implementations must be safe for concurrent use, e.g. an in-memory map or a shared redis.
+type RateLimitStore interface {
+	// Take consumes one request for key, which allows limit requests per window.
+	Take(key string, limit int, window time.Duration) RateLimitResult
+}
+
+// MemoryRateLimitStore is an in-memory RateLimitStore using token bucket algorithm (implemented as GCRA).
+// Keys are removed after their buckets are full again, so memory is bounded by the number of active clients.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	tat       map[string]time.Time // theoretical arrival time of each key
+	interval  time.Duration
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore creates a MemoryRateLimitStore that removes expired keys every sweepInterval.
+func NewMemoryRateLimitStore(sweepInterval time.Duration) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		tat:       make(map[string]time.Time),
+		interval:  sweepInterval,
+		lastSweep: time.Now(),
+	}
+}
+
+// Take implements RateLimitStore. A full bucket contains limit tokens, and one token is refilled every window/limit.
+func (s *MemoryRateLimitStore) Take(key string, limit int, window time.Duration) (res RateLimitResult) {
+	now := time.Now()
+	emission := window / time.Duration(limit)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= s.interval {
+		s.sweep(now)
+	}
+
+	tat, ok := s.tat[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+	if next := tat.Add(emission); next.Sub(now) > window {
+		res.RetryAfter = next.Sub(now) - window
+	} else {
+		tat = next
+		s.tat[key] = tat
+		res.Allowed = true
+	}
+	res.Reset = tat.Sub(now)
+	res.Remaining = int((window - res.Reset) / emission)
+	return res
+}
+
+// Len returns the number of keys currently stored.
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tat)
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, tat := range s.tat {
+		if !tat.After(now) {
+			delete(s.tat, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// RateLimitOptions configures the RateLimit middleware.
+type RateLimitOptions struct {
+	Limit  int           // requests allowed per Window, also the burst size, at most one per nanosecond
+	Window time.Duration // default 1 minute
+
+	KeyFunc   func(*Store) string // default Store.GetClientIP()
+	Store     RateLimitStore      // default NewMemoryRateLimitStore(Window)
+	OnLimited HandlerFunc         // default replies 429 with plain text
+}
+
+// RateLimit returns a middleware that limits requests of each key with RateLimitStore.
+// It sets 'RateLimit-Limit', 'RateLimit-Remaining' and 'RateLimit-Reset' headers for every request,
+// and 'Retry-After' header for limited requests which are handled by OnLimited.
+// Warning: The default KeyFunc Store.GetClientIP() may be spoofed by forwarding headers to bypass the limit and
+// grow the keys of RateLimitStore. Use Mux.SetTrustedProxies() to honor forwarding headers only from trusted proxies.
+func RateLimit(opts RateLimitOptions) HandlerFunc {
+	if opts.Limit <= 0 {
+		panic("httpd: rate limit must be positive")
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Window/time.Duration(opts.Limit) == 0 {
+		panic("httpd: rate limit must not exceed one request per nanosecond of window")
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = (*Store).GetClientIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore(opts.Window)
+	}
+	if opts.OnLimited == nil {
+		opts.OnLimited = func(store *Store) { http.Error(store.W, "429 too many requests", http.StatusTooManyRequests) }
+	}
+	limit := strconv.Itoa(opts.Limit)
+
+	return func(store *Store) {
+		res := opts.Store.Take(opts.KeyFunc(store), opts.Limit, opts.Window)
+		header := store.W.Header()
+		header.Set("RateLimit-Limit", limit)
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if res.Allowed {
+			store.Next()
+			return
+		}
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		opts.OnLimited(store)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
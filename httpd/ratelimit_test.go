@@ -0,0 +1,94 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := httpd.NewMemoryRateLimitStore(time.Hour)
+	for i := range 3 {
+		res := s.Take("a", 3, time.Minute)
+		if !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("Take(a) #%d = %+v, want allowed with remaining %d", i, res, 2-i)
+		}
+	}
+	if res := s.Take("a", 3, time.Minute); res.Allowed || res.Remaining != 0 || res.RetryAfter <= 0 || res.RetryAfter > 20*time.Second {
+		t.Fatalf("Take(a) after burst = %+v, want limited with RetryAfter in (0, 20s]", res)
+	}
+	if res := s.Take("b", 3, time.Minute); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("Take(b) = %+v, want allowed with remaining 2", res)
+	}
+
+	// tokens are refilled over time, and keys are removed after full refill
+	s = httpd.NewMemoryRateLimitStore(10 * time.Millisecond)
+	s.Take("a", 2, 20*time.Millisecond)
+	s.Take("a", 2, 20*time.Millisecond)
+	if res := s.Take("a", 2, 20*time.Millisecond); res.Allowed {
+		t.Fatalf("Take(a) after burst = %+v, want limited", res)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if res := s.Take("b", 2, 20*time.Millisecond); !res.Allowed || s.Len() != 1 {
+		t.Fatalf("Take(b) after refill = %+v with Len() %d, want allowed with Len() 1", res, s.Len())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.RateLimit(httpd.RateLimitOptions{
+		Limit:   2,
+		Window:  time.Minute,
+		KeyFunc: func(s *httpd.Store) string { return s.R.Header.Get("X-API-Key") },
+	}))
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte("ok")) })
+
+	tests := []struct {
+		key       string
+		code      int
+		remaining int
+	}{
+		{"a", 200, 1},
+		{"a", 200, 0},
+		{"a", 429, 0},
+		{"b", 200, 1},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-API-Key", tt.key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Fatalf("key %q return %d, want %d", tt.key, w.Code, tt.code)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Fatalf("key %q return RateLimit-Limit %q, want %q", tt.key, got, "2")
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != strconv.Itoa(tt.remaining) {
+			t.Fatalf("key %q return RateLimit-Remaining %q, want %d", tt.key, got, tt.remaining)
+		}
+		if got := w.Header().Get("Retry-After"); (tt.code == 429) != (got == "30") {
+			t.Fatalf("key %q return Retry-After %q", tt.key, got)
+		}
+	}
+}
+
+func TestRateLimitInvalidOptions(t *testing.T) {
+	for _, opts := range []httpd.RateLimitOptions{
+		{Limit: 0},
+		{Limit: 10, Window: 5 * time.Nanosecond},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("RateLimit(%+v) should panic", opts)
+				}
+			}()
+			httpd.RateLimit(opts)
+		}()
+	}
+}
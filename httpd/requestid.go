@@ -0,0 +1,63 @@
+package httpd
+
+import (
+	"context"
+
+	"github.com/whoisnian/glb/tasklane"
+)
+
+type requestIDCtxKey struct{}
+
+// RequestIDOptions configures the RequestID middleware.
+type RequestIDOptions struct {
+	Header   string        // request and response header name, default "X-Request-ID"
+	Generate func() string // default uses tasklane.Sequence with random prefix
+}
+
+// RequestID returns a middleware that reuses valid request ID from request header or generates a new one.
+// The request ID is set to response header and request context, and can be retrieved by Store.RequestID().
+// It should be registered before logger middleware to include request ID in all request logs.
+func RequestID(opts RequestIDOptions) HandlerFunc {
+	if opts.Header == "" {
+		opts.Header = "X-Request-ID"
+	}
+	if opts.Generate == nil {
+		seq := tasklane.NewSequence(8)
+		opts.Generate = func() string { return string(seq.Next()) }
+	}
+
+	return func(store *Store) {
+		id := store.R.Header.Get(opts.Header)
+		if !isValidRequestID(id) {
+			id = opts.Generate()
+		}
+		store.Set(KeyRequestID, id)
+		store.W.Header().Set(opts.Header, id)
+		store.R = store.R.WithContext(context.WithValue(store.R.Context(), requestIDCtxKey{}, id))
+		store.Next()
+	}
+}
+
+// isValidRequestID accepts at most 128 printable ASCII characters, which is safe to be logged.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the request ID set by RequestID middleware, or empty string if not set.
+func (store *Store) RequestID() string {
+	return GetOr(store, KeyRequestID, "")
+}
+
+// RequestIDFromContext returns the request ID set by RequestID middleware, or empty string if not set.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
@@ -0,0 +1,59 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestRequestID(t *testing.T) {
+	var storeID, ctxID string
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.RequestID(httpd.RequestIDOptions{}))
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) {
+		storeID, ctxID = s.RequestID(), httpd.RequestIDFromContext(s.R.Context())
+	})
+
+	tests := []struct {
+		incoming string
+		reuse    bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"with space", false},
+		{"bad\nline", false},
+		{strings.Repeat("a", 129), false},
+	}
+	var generated []string
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-ID", tt.incoming)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+
+		got := w.Header().Get("X-Request-ID")
+		if got == "" || got != storeID || got != ctxID {
+			t.Fatalf("incoming %q got header %q, store %q, context %q", tt.incoming, got, storeID, ctxID)
+		}
+		if tt.reuse && got != tt.incoming {
+			t.Fatalf("incoming %q got %q, want reused", tt.incoming, got)
+		} else if !tt.reuse {
+			if got == tt.incoming || len(generated) > 0 && got == generated[len(generated)-1] {
+				t.Fatalf("incoming %q got %q, want newly generated", tt.incoming, got)
+			}
+			generated = append(generated, got)
+		}
+	}
+
+	mux = httpd.NewMux()
+	mux.HandleMiddleware(httpd.RequestID(httpd.RequestIDOptions{Header: "X-Trace", Generate: func() string { return "fixed" }}))
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) { storeID = s.RequestID() })
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := w.Header().Get("X-Trace"); got != "fixed" || storeID != "fixed" {
+		t.Fatalf("custom options got header %q, store %q, want %q", got, storeID, "fixed")
+	}
+}
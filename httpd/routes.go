@@ -0,0 +1,234 @@
+package httpd
+
+import (
+	"fmt"
+	"io"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// MiddlewareNames returns the function names of middlewares in the chain of route.
+func (info *RouteInfo) MiddlewareNames() []string {
+	names := make([]string, len(*info.Middlewares))
+	for i, mw := range *info.Middlewares {
+		names[i] = nameOfFunc(mw)
+	}
+	return names
+}
+
+// Routes returns all registered routes sorted by path and then by method.
+func (mux *Mux) Routes() []*RouteInfo {
+	mux.mu.RLock()
+	routes := slices.Clone(mux.routes)
+	mux.mu.RUnlock()
+
+	slices.SortStableFunc(routes, func(a, b *RouteInfo) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return methodIndex(a.Method) - methodIndex(b.Method)
+	})
+	return routes
+}
+
+func methodIndex(method string) int {
+	if i := slices.Index(methodList, method); i >= 0 {
+		return i
+	}
+	return len(methodList) // MethodAll
+}
+
+// RouteConflict reports a route that is unreachable because all of its requests are matched by other routes.
+type RouteConflict struct {
+	Route      *RouteInfo
+	ShadowedBy *RouteInfo
+}
+
+// probeMethod is an extension method that only matches MethodAll.
+const probeMethod = "PROBE"
+
+// Conflicts returns the routes that are unreachable because of shadowing.
+// It probes each route with sample requests generated from its routePath, so the result is a best-effort report.
+// Routes with constraints that no sample can be generated for are skipped.
+func (mux *Mux) Conflicts() (result []RouteConflict) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	for _, info := range mux.routes {
+		paths := samplePaths(info.Path)
+		if len(paths) == 0 {
+			continue
+		}
+		method := info.Method
+		if method == MethodAll {
+			method = probeMethod
+		}
+
+		var reachable bool
+		var shadowedBy *RouteInfo
+		for _, path := range paths {
+			res, _, _ := findRoute(mux.root, path, method, &Params{})
+			if res == info {
+				reachable = true
+				break
+			} else if shadowedBy == nil {
+				shadowedBy = res
+			}
+		}
+		if !reachable && shadowedBy != nil {
+			result = append(result, RouteConflict{Route: info, ShadowedBy: shadowedBy})
+		}
+	}
+	return result
+}
+
+// PrintRoutes writes all registered routes as a table, followed by warnings of route conflicts and duplicate names.
+func (mux *Mux) PrintRoutes(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tNAME\tHANDLER\tMIDDLEWARES")
+	var names, duplicates []string
+	for _, info := range mux.Routes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", info.Method, info.Path, info.Name, info.HandlerName, strings.Join(info.MiddlewareNames(), ","))
+		if info.Name == "" {
+			continue
+		} else if slices.Contains(names, info.Name) && !slices.Contains(duplicates, info.Name) {
+			duplicates = append(duplicates, info.Name)
+		}
+		names = append(names, info.Name)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, name := range duplicates {
+		if _, err := fmt.Fprintf(w, "WARNING: route name %s is used by multiple routes\n", name); err != nil {
+			return err
+		}
+	}
+	for _, c := range mux.Conflicts() {
+		if _, err := fmt.Fprintf(w, "WARNING: route %s %s is shadowed by %s %s\n", c.Route.Method, c.Route.Path, c.ShadowedBy.Method, c.ShadowedBy.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxSamplePaths limits the number of sample paths generated for one route.
+const maxSamplePaths = 64
+
+// samplePaths generates sample request paths that should be matched by the routePath.
+func samplePaths(path string) []string {
+	paths := []string{""}
+	var length, left, right int = len(path), 0, 0
+	for ; right <= length; right++ {
+		if right < length && path[right] != '/' {
+			continue
+		}
+		var values []string
+		if right-left < 2 {
+			left = right
+			continue // skip empty fragment
+		} else if path[left+1:right] == "*" {
+			values = []string{"x", "x/x"}
+		} else if path[left+1] == ':' {
+			_, constraint, _ := parseParamFragment(path[left+1 : right])
+			values = sampleValues(constraint)
+		} else {
+			values = []string{path[left+1 : right]}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+
+		next := make([]string, 0, min(len(paths)*len(values), maxSamplePaths))
+		for _, p := range paths {
+			for _, v := range values {
+				if len(next) < maxSamplePaths {
+					next = append(next, p+"/"+v)
+				}
+			}
+		}
+		paths = next
+		if path[left+1:right] == "*" {
+			break
+		}
+		left = right
+	}
+	if paths[0] == "" {
+		return []string{"/"}
+	}
+	return paths
+}
+
+// sampleValues generates sample param values that satisfy the constraint.
+func sampleValues(constraint string) (values []string) {
+	candidates := []string{"x", "0", "-1", "00000000-0000-0000-0000-000000000000"}
+	if constraint == "" {
+		return candidates
+	}
+	check, err := compileConstraint(constraint)
+	if err != nil {
+		return nil
+	}
+	if re, err := syntax.Parse(constraint, syntax.Perl); err == nil {
+		candidates = append(candidates, regexpSamples(re.Simplify())...)
+	}
+	for _, v := range candidates {
+		if v != "" && !strings.ContainsRune(v, '/') && check(v) && !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// maxRegexpSamples limits the number of sample strings generated for one regexp node.
+const maxRegexpSamples = 8
+
+// regexpSamples generates some short strings that may match the regexp.
+func regexpSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		var res []string
+		for i := 0; i+1 < len(re.Rune) && len(res) < maxRegexpSamples; i += 2 {
+			res = append(res, string(re.Rune[i]))
+		}
+		return res
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"x"}
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexpSamples(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return append([]string{""}, regexpSamples(re.Sub[0])...)
+	case syntax.OpRepeat:
+		var res []string
+		for _, s := range regexpSamples(re.Sub[0]) {
+			res = append(res, strings.Repeat(s, re.Min))
+		}
+		return res
+	case syntax.OpConcat:
+		res := []string{""}
+		for _, sub := range re.Sub {
+			var next []string
+			for _, prefix := range res {
+				for _, s := range regexpSamples(sub) {
+					if len(next) < maxRegexpSamples {
+						next = append(next, prefix+s)
+					}
+				}
+			}
+			res = next
+		}
+		return res
+	case syntax.OpAlternate:
+		var res []string
+		for _, sub := range re.Sub {
+			res = append(res, regexpSamples(sub)...)
+		}
+		return res[:min(len(res), maxRegexpSamples)]
+	default: // empty-width assertions
+		return []string{""}
+	}
+}
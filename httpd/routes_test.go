@@ -0,0 +1,102 @@
+package httpd_test
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func testRoutesHandler(*httpd.Store)    {}
+func testRoutesMiddleware(*httpd.Store) {}
+
+func TestRoutes(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(testRoutesMiddleware)
+	mux.Handle("/bbb", httpd.MethodAll, testRoutesHandler)
+	mux.Handle("/bbb", http.MethodPost, testRoutesHandler)
+	mux.Handle("/aaa/:id", http.MethodGet, testRoutesHandler).Name = "aaa"
+	mux.Group("/ccc", testRoutesMiddleware).Handle("/*", http.MethodDelete, testRoutesHandler)
+	mux.Handle("/bbb", http.MethodGet, testRoutesHandler)
+
+	want := []string{"/aaa/:id GET", "/bbb GET", "/bbb POST", "/bbb *", "/ccc/* DELETE"}
+	var got []string
+	for _, info := range mux.Routes() {
+		got = append(got, info.Path+" "+info.Method)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Routes() = %q, want %q", got, want)
+	}
+
+	names := mux.Routes()[4].MiddlewareNames()
+	wantNames := []string{"github.com/whoisnian/glb/httpd_test.testRoutesMiddleware", "github.com/whoisnian/glb/httpd_test.testRoutesMiddleware"}
+	if !slices.Equal(names, wantNames) {
+		t.Fatalf("MiddlewareNames() = %q, want %q", names, wantNames)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.Handle("/files/new/edit", http.MethodGet, testRoutesHandler)
+	mux.Handle("/files/:id/view", http.MethodGet, testRoutesHandler)
+	mux.Handle("/files/*", http.MethodGet, testRoutesHandler)
+	mux.Handle("/users/:id<[0-9]+>", http.MethodGet, testRoutesHandler)
+	mux.Handle("/users/:id<int>", http.MethodGet, testRoutesHandler)
+	mux.Handle("/posts/:slug<.+>", http.MethodGet, testRoutesHandler)
+	mux.Handle("/posts/:id<int>", http.MethodGet, testRoutesHandler)
+	mux.Handle("/posts/:name", http.MethodGet, testRoutesHandler)
+	mux.Handle("/tags/:name<[a-z]+|[0-9]{2}>", http.MethodGet, testRoutesHandler)
+	mux.Handle("/tags/:id<int>", http.MethodGet, testRoutesHandler)
+	mux.Handle("/tags/:tag<x>", http.MethodGet, testRoutesHandler)
+
+	want := []string{
+		"/posts/:id<int> GET shadowed by /posts/:slug<.+>",
+		"/posts/:name GET shadowed by /posts/:slug<.+>",
+		"/tags/:tag<x> GET shadowed by /tags/:name<[a-z]+|[0-9]{2}>",
+	}
+	var got []string
+	for _, c := range mux.Conflicts() {
+		got = append(got, c.Route.Path+" "+c.Route.Method+" shadowed by "+c.ShadowedBy.Path)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Conflicts() = %q, want %q", got, want)
+	}
+}
+
+func TestConflictsMethodAll(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.Handle("/aaa", httpd.MethodAll, testRoutesHandler)
+	mux.Handle("/aaa", http.MethodGet, testRoutesHandler)
+	mux.Handle("/bbb", http.MethodGet, testRoutesHandler)
+	mux.Handle("/bbb", http.MethodHead, testRoutesHandler)
+	if got := mux.Conflicts(); len(got) != 0 {
+		t.Fatalf("Conflicts() = %v, want none", got)
+	}
+}
+
+func TestPrintRoutes(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.Handle("/aaa/:id", http.MethodGet, testRoutesHandler).Name = "aaa"
+	mux.Handle("/aaa/:name<.*>", http.MethodGet, testRoutesHandler).Name = "aaa"
+
+	var buf bytes.Buffer
+	if err := mux.PrintRoutes(&buf); err != nil {
+		t.Fatalf("PrintRoutes: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("PrintRoutes() output %d lines, want %d:\n%s", len(lines), 5, buf.String())
+	}
+	if fields := strings.Fields(lines[1]); !slices.Equal(fields, []string{"GET", "/aaa/:id", "aaa", "github.com/whoisnian/glb/httpd_test.testRoutesHandler"}) {
+		t.Fatalf("PrintRoutes() line %q is unexpected", lines[1])
+	}
+	if want := "WARNING: route name aaa is used by multiple routes"; lines[3] != want {
+		t.Fatalf("PrintRoutes() line %q, want %q", lines[3], want)
+	}
+	if want := "WARNING: route GET /aaa/:id is shadowed by GET /aaa/:name<.*>"; lines[4] != want {
+		t.Fatalf("PrintRoutes() line %q, want %q", lines[4], want)
+	}
+}
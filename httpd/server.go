@@ -0,0 +1,148 @@
+package httpd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// Server manages listeners and lifecycle of http.Server, and shuts down gracefully on SIGINT or SIGTERM.
+type Server struct {
+	Handler http.Handler
+
+	// Addrs are the addresses to listen on, e.g. ":8080", "127.0.0.1:8080", "[::1]:8080" or "unix:/run/app.sock".
+	// Existing unix socket file is removed only if no process is listening on it.
+	Addrs []string
+
+	// TLSConfig enables TLS on all listeners if set. It must contain certificates.
+	TLSConfig *tls.Config
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// ShutdownTimeout is the max duration to drain in-flight requests, default 30s.
+	ShutdownTimeout time.Duration
+
+	onStart    []func(addrs []net.Addr)
+	onShutdown []func(ctx context.Context)
+}
+
+// OnStart registers hook that is called after all listeners are ready.
+func (s *Server) OnStart(hook func(addrs []net.Addr)) {
+	s.onStart = append(s.onStart, hook)
+}
+
+// OnShutdown registers hook that is called concurrently when shutdown starts, before in-flight requests are drained.
+// It should stop long-lived handlers like event streams or hijacked WebSocket connections, which are not drained
+// by http.Server. The ctx will be done when ShutdownTimeout is exceeded, and Run waits for all hooks to return.
+func (s *Server) OnShutdown(hook func(ctx context.Context)) {
+	s.onShutdown = append(s.onShutdown, hook)
+}
+
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		path = strings.TrimPrefix(path, "//")
+		if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+			// remove stale socket file only, as another process may still listen on it
+			if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+				conn.Close()
+				return nil, errors.New("httpd: unix socket " + path + " is in use by another process")
+			} else if errors.Is(err, syscall.ECONNREFUSED) {
+				os.Remove(path)
+			}
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Run listens on all addresses and serves requests until ctx is done, SIGINT or SIGTERM is received,
+// or any listener fails. Then it stops accepting new connections and drains in-flight requests within ShutdownTimeout.
+func (s *Server) Run(ctx context.Context) error {
+	if len(s.Addrs) == 0 {
+		return errors.New("httpd: Server.Addrs is empty")
+	}
+	listeners := make([]net.Listener, 0, len(s.Addrs))
+	addrs := make([]net.Addr, 0, len(s.Addrs))
+	for _, addr := range s.Addrs {
+		ln, err := listen(addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, ln)
+		addrs = append(addrs, ln.Addr())
+	}
+
+	server := &http.Server{
+		Handler:           s.Handler,
+		TLSConfig:         s.TLSConfig,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		wg.Go(func() {
+			var err error
+			if s.TLSConfig != nil {
+				err = server.ServeTLS(ln, "", "")
+			} else {
+				err = server.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				errCh <- err
+			}
+		})
+	}
+	for _, hook := range s.onStart {
+		hook(addrs)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errCh:
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var hooks sync.WaitGroup
+	for _, hook := range s.onShutdown {
+		hooks.Add(1)
+		server.RegisterOnShutdown(func() {
+			defer hooks.Done()
+			hook(shutdownCtx)
+		})
+	}
+	shutdownErr := server.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		server.Close()
+	}
+	wg.Wait()
+	hooks.Wait()
+	return errors.Join(serveErr, shutdownErr)
+}
@@ -0,0 +1,206 @@
+package httpd_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestServer(t *testing.T) {
+	dir, err := os.MkdirTemp("", "glb") // short path for unix socket
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sockPath := filepath.Join(dir, "test.sock")
+
+	release := make(chan struct{})
+	mux := httpd.NewMux()
+	mux.Handle("/ping", http.MethodGet, func(s *httpd.Store) { s.Respond200([]byte("pong")) })
+	mux.Handle("/slow", http.MethodGet, func(s *httpd.Store) { <-release; s.Respond200([]byte("done")) })
+
+	server := &httpd.Server{Handler: mux, Addrs: []string{"127.0.0.1:0", "unix:" + sockPath}}
+	started := make(chan []net.Addr, 1)
+	server.OnStart(func(addrs []net.Addr) { started <- addrs })
+	var shutdownCalled bool
+	server.OnShutdown(func(ctx context.Context) { shutdownCalled = true })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- server.Run(ctx) }()
+	addrs := <-started
+	if len(addrs) != 2 || addrs[1].Network() != "unix" {
+		t.Fatalf("OnStart() got addrs %v, want tcp and unix", addrs)
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
+		},
+	}}
+	for _, get := range []func(string) (*http.Response, error){
+		func(path string) (*http.Response, error) { return http.Get("http://" + addrs[0].String() + path) },
+		func(path string) (*http.Response, error) { return unixClient.Get("http://unix" + path) },
+	} {
+		resp, err := get("/ping")
+		if err != nil {
+			t.Fatalf("GET /ping: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(data) != "pong" {
+			t.Fatalf("GET /ping return %q, want %q", data, "pong")
+		}
+	}
+
+	// in-flight request should be drained before Run returns
+	slowResp := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addrs[0].String() + "/slow")
+		if err != nil {
+			slowResp <- err.Error()
+			return
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		slowResp <- string(data)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if got := <-slowResp; got != "done" {
+		t.Fatalf("GET /slow return %q, want %q", got, "done")
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !shutdownCalled {
+		t.Fatal("OnShutdown hook should be called")
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.Handle("/slow", http.MethodGet, func(s *httpd.Store) { <-s.R.Context().Done() })
+
+	server := &httpd.Server{Handler: mux, Addrs: []string{"127.0.0.1:0"}, ShutdownTimeout: 50 * time.Millisecond}
+	started := make(chan []net.Addr, 1)
+	server.OnStart(func(addrs []net.Addr) { started <- addrs })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- server.Run(ctx) }()
+	addrs := <-started
+
+	go http.Get("http://" + addrs[0].String() + "/slow")
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-runErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() return %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestServerListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	server := &httpd.Server{Handler: httpd.NewMux(), Addrs: []string{"127.0.0.1:0", ln.Addr().String()}}
+	if err := server.Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail if address is in use")
+	}
+}
+
+func TestServerShutdownHook(t *testing.T) {
+	hub := httpd.NewEventHub(8)
+	mux := httpd.NewMux()
+	mux.Handle("/events", http.MethodGet, func(s *httpd.Store) { hub.Serve(s, 0) })
+
+	server := &httpd.Server{Handler: mux, Addrs: []string{"127.0.0.1:0"}, ShutdownTimeout: 5 * time.Second}
+	started := make(chan []net.Addr, 1)
+	server.OnStart(func(addrs []net.Addr) { started <- addrs })
+	var hookErr error
+	server.OnShutdown(func(ctx context.Context) {
+		hookErr = ctx.Err()
+		hub.Close()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- server.Run(ctx) }()
+	addrs := <-started
+
+	resp, err := http.Get("http://" + addrs[0].String() + "/events")
+	if err != nil {
+		t.Fatalf("GET /events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if hookErr != nil {
+		t.Fatalf("OnShutdown hook got ctx.Err() = %v, want nil", hookErr)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run returned after %v, want event stream to be closed by hook", elapsed)
+	}
+}
+
+func TestServerUnixSocketInUse(t *testing.T) {
+	dir, err := os.MkdirTemp("", "glb") // short path for unix socket
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sockPath := filepath.Join(dir, "test.sock")
+
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	server := &httpd.Server{Handler: httpd.NewMux(), Addrs: []string{"unix:" + sockPath}}
+	if err := server.Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail if unix socket is in use")
+	}
+	if _, err := os.Stat(sockPath); err != nil {
+		t.Fatalf("socket file in use should be kept, but got %v", err)
+	}
+
+	ln.Close() // stale socket file is left
+	started := make(chan []net.Addr, 1)
+	server.OnStart(func(addrs []net.Addr) { started <- addrs })
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- server.Run(ctx) }()
+	select {
+	case <-started:
+	case err := <-runErr:
+		t.Fatalf("Run() with stale socket file: %v", err)
+	}
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestServerEmptyAddrs(t *testing.T) {
+	if err := (&httpd.Server{Handler: httpd.NewMux()}).Run(context.Background()); err == nil {
+		t.Fatal("Run() should fail without Addrs")
+	}
+}
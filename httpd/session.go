@@ -0,0 +1,391 @@
+package httpd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionStore stores session values on server side, and the session cookie only contains the session ID.
+// Implementations must be safe for concurrent use.
+type SessionStore interface {
+	// Load returns values of session id, or nil map if not found or expired.
+	Load(id string) (map[string]string, error)
+	// Save stores values of session id, which expire after ttl.
+	Save(id string, values map[string]string, ttl time.Duration) error
+	// Delete removes session id.
+	Delete(id string) error
+}
+
+// MemorySessionStore is an in-memory SessionStore, which removes expired sessions every sweepInterval.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	interval  time.Duration
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	values map[string]string
+	expiry time.Time
+}
+
+// NewMemorySessionStore creates a MemorySessionStore that removes expired sessions every sweepInterval.
+func NewMemorySessionStore(sweepInterval time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession), interval: sweepInterval, lastSweep: time.Now()}
+}
+
+// Load implements SessionStore.
+func (s *MemorySessionStore) Load(id string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; ok && time.Now().Before(session.expiry) {
+		return cloneValues(session.values), nil
+	}
+	return nil, nil
+}
+
+// Save implements SessionStore.
+func (s *MemorySessionStore) Save(id string, values map[string]string, ttl time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= s.interval {
+		for k, session := range s.sessions {
+			if !now.Before(session.expiry) {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.sessions[id] = memorySession{values: cloneValues(values), expiry: now.Add(ttl)}
+	return nil
+}
+
+// Delete implements SessionStore.
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+func cloneValues(values map[string]string) map[string]string {
+	res := make(map[string]string, len(values))
+	for k, v := range values {
+		res[k] = v
+	}
+	return res
+}
+
+// SessionOptions configures the Sessions middleware.
+type SessionOptions struct {
+	// Keys sign (and encrypt if Encrypt is true) session cookies. The first key is used for new cookies, and
+	// the others are only used to verify existing cookies, which are re-issued with the first key (key rotation).
+	// Each key should have at least 32 random bytes.
+	Keys    [][]byte
+	Encrypt bool          // encrypt session cookies with AES-GCM, otherwise cookies are only signed
+	MaxAge  time.Duration // session expiry, refreshed when session is saved, default 24 hours
+	Store   SessionStore  // store values on server side if not nil, otherwise values are stored in cookie
+
+	CookieName string // default "session"
+	Path       string // default "/"
+	Domain     string
+	Secure     bool
+	SameSite   http.SameSite // default http.SameSiteLaxMode
+
+	// OnError is called if session fails to be saved, e.g. error of Store or cookie exceeding 4096 bytes,
+	// which is rejected as browsers drop it silently. Errors are ignored if nil.
+	OnError func(store *Store, err error)
+}
+
+// maxCookieSize is the cookie size limit of most browsers, including name, value and attributes.
+const maxCookieSize = 4096
+
+// Session is a map-like session of request, which is saved automatically before response header is written.
+// Values are stored in cookie by default, so they should be small.
+type Session struct {
+	id      string
+	values  map[string]string
+	isNew   bool
+	dirty   bool
+	cleared bool
+	renewed bool
+	saved   bool
+}
+
+// Get returns the value of key. The ok result indicates whether key was found.
+func (s *Session) Get(key string) (value string, ok bool) {
+	value, ok = s.values[key]
+	return value, ok
+}
+
+// Set sets the value of key.
+func (s *Session) Set(key, value string) {
+	if s.values == nil {
+		s.values = make(map[string]string)
+	}
+	s.values[key] = value
+	s.dirty, s.cleared = true, false
+}
+
+// Delete removes the value of key.
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.dirty = true
+	}
+}
+
+// Len returns the number of values.
+func (s *Session) Len() int {
+	return len(s.values)
+}
+
+// IsNew reports whether session is created by current request.
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Clear removes all values and destroys the session, e.g. on logout.
+// Values set after Clear are saved with a new session ID of server side store.
+func (s *Session) Clear() {
+	clear(s.values)
+	s.dirty, s.cleared, s.renewed = true, true, true
+}
+
+// Renew keeps values but changes session ID of server side store, which prevents session fixation, e.g. on login.
+func (s *Session) Renew() {
+	s.dirty, s.renewed = true, true
+}
+
+// Session returns the session loaded by Sessions middleware, or nil if Sessions middleware is not used.
+func (store *Store) Session() *Session {
+	return store.session
+}
+
+type sessionPayload struct {
+	ID     string            `json:"i,omitempty"`
+	Values map[string]string `json:"v,omitempty"`
+	Expiry int64             `json:"e"`
+}
+
+// sessionCodec signs and encrypts session payload with derived keys.
+type sessionCodec struct {
+	name  string
+	signs [][]byte
+	aeads []cipher.AEAD // nil if encryption is disabled
+}
+
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func newSessionCodec(name string, keys [][]byte, encrypt bool) (*sessionCodec, error) {
+	codec := &sessionCodec{name: name}
+	for _, key := range keys {
+		codec.signs = append(codec.signs, deriveKey(key, "httpd session sign"))
+		if encrypt {
+			block, err := aes.NewCipher(deriveKey(key, "httpd session encrypt"))
+			if err != nil {
+				return nil, err
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				return nil, err
+			}
+			codec.aeads = append(codec.aeads, aead)
+		}
+	}
+	return codec, nil
+}
+
+func (c *sessionCodec) sign(i int, data string) []byte {
+	mac := hmac.New(sha256.New, c.signs[i])
+	mac.Write([]byte(c.name + "|" + data))
+	return mac.Sum(nil)
+}
+
+// encode returns "base64(data).base64(signature)", where data is encrypted by the first key if enabled.
+func (c *sessionCodec) encode(payload *sessionPayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	if c.aeads != nil {
+		nonce := make([]byte, c.aeads[0].NonceSize())
+		rand.Read(nonce) // crypto/rand.Read() never returns an error
+		data = c.aeads[0].Seal(nonce, nonce, data, []byte(c.name))
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(0, encoded)), nil
+}
+
+// decode verifies value with all keys, and returns the index of matched key.
+func (c *sessionCodec) decode(value string) (payload *sessionPayload, keyIndex int, err error) {
+	encoded, sig, ok := strings.Cut(value, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(sig)
+	if !ok || err != nil {
+		return nil, 0, errors.New("httpd: invalid session cookie")
+	}
+	keyIndex = -1
+	for i := range c.signs {
+		if hmac.Equal(signature, c.sign(i, encoded)) {
+			keyIndex = i
+			break
+		}
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if keyIndex < 0 || err != nil {
+		return nil, 0, errors.New("httpd: invalid session cookie signature")
+	}
+	if c.aeads != nil {
+		aead := c.aeads[keyIndex]
+		if len(data) < aead.NonceSize() {
+			return nil, 0, errors.New("httpd: invalid session cookie data")
+		}
+		if data, err = aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(c.name)); err != nil {
+			return nil, 0, err
+		}
+	}
+	payload = new(sessionPayload)
+	if err = json.Unmarshal(data, payload); err != nil {
+		return nil, 0, err
+	}
+	if time.Now().Unix() >= payload.Expiry {
+		return nil, 0, errors.New("httpd: session cookie expired")
+	}
+	return payload, keyIndex, nil
+}
+
+// Sessions returns a middleware that loads session from cookie for Store.Session(), and saves it before response
+// header is written if session is modified. Invalid or expired cookies are treated as new sessions.
+func Sessions(opts SessionOptions) HandlerFunc {
+	if len(opts.Keys) == 0 {
+		panic("httpd: Sessions requires at least one key")
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 24 * time.Hour
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = "session"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	codec, err := newSessionCodec(opts.CookieName, opts.Keys, opts.Encrypt)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(store *Store) {
+		session := loadSession(store, codec, &opts)
+		store.session = session
+		store.W.beforeHeader = append(store.W.beforeHeader, func() { saveSession(store, session, codec, &opts) })
+		store.Next()
+		if store.W.Status == 0 {
+			saveSession(store, session, codec, &opts) // handler returns without writing response
+		}
+	}
+}
+
+func loadSession(store *Store, codec *sessionCodec, opts *SessionOptions) *Session {
+	session := &Session{isNew: true}
+	cookie, err := store.R.Cookie(opts.CookieName)
+	if err != nil {
+		return session
+	}
+	payload, keyIndex, err := codec.decode(cookie.Value)
+	if err != nil {
+		return session
+	}
+	if opts.Store == nil {
+		session.values = payload.Values
+	} else if session.values, err = opts.Store.Load(payload.ID); err != nil || session.values == nil {
+		return session
+	} else {
+		session.id = payload.ID
+	}
+	session.isNew = false
+	session.dirty = keyIndex > 0 // re-issue cookie with the first key
+	return session
+}
+
+func saveSession(store *Store, session *Session, codec *sessionCodec, opts *SessionOptions) {
+	if session.saved || !session.dirty {
+		return
+	}
+	session.saved = true
+
+	cookie := &http.Cookie{
+		Name:     opts.CookieName,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		Secure:   opts.Secure,
+		HttpOnly: true,
+		SameSite: opts.SameSite,
+	}
+	if session.cleared {
+		if opts.Store != nil && session.id != "" {
+			if err := opts.Store.Delete(session.id); err != nil {
+				reportSessionError(store, opts, err)
+			}
+		}
+		cookie.MaxAge = -1
+		http.SetCookie(store.W, cookie)
+		return
+	}
+
+	payload := &sessionPayload{Expiry: time.Now().Add(opts.MaxAge).Unix()}
+	if opts.Store == nil {
+		payload.Values = session.values
+	} else {
+		oldID := session.id
+		if session.id == "" || session.renewed {
+			session.id = rand.Text()
+		}
+		if err := opts.Store.Save(session.id, session.values, opts.MaxAge); err != nil {
+			reportSessionError(store, opts, err)
+			return
+		}
+		if oldID != "" && oldID != session.id {
+			if err := opts.Store.Delete(oldID); err != nil {
+				reportSessionError(store, opts, err)
+			}
+		}
+		payload.ID = session.id
+	}
+	value, err := codec.encode(payload)
+	if err != nil {
+		reportSessionError(store, opts, err)
+		return
+	}
+	cookie.Value = value
+	cookie.MaxAge = int(opts.MaxAge / time.Second)
+	if size := len(cookie.String()); size > maxCookieSize {
+		reportSessionError(store, opts, errors.New("httpd: session cookie of "+strconv.Itoa(size)+" bytes exceeds 4096 bytes, use SessionOptions.Store for large values"))
+		return
+	}
+	http.SetCookie(store.W, cookie)
+}
+
+func reportSessionError(store *Store, opts *SessionOptions, err error) {
+	if opts.OnError != nil {
+		opts.OnError(store, err)
+	}
+}
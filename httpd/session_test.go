@@ -0,0 +1,166 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+// sessionClient keeps cookies between requests like browser.
+type sessionClient struct {
+	t      *testing.T
+	mux    *httpd.Mux
+	cookie *http.Cookie
+}
+
+func (c *sessionClient) do(path string) (body string, setCookie *http.Cookie) {
+	c.t.Helper()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if c.cookie != nil {
+		r.AddCookie(c.cookie)
+	}
+	w := httptest.NewRecorder()
+	c.mux.ServeHTTP(w, r)
+	if cookies := w.Result().Cookies(); len(cookies) > 0 {
+		setCookie = cookies[0]
+		if c.cookie = setCookie; setCookie.MaxAge < 0 {
+			c.cookie = nil
+		}
+	}
+	return w.Body.String(), setCookie
+}
+
+func newSessionMux(opts httpd.SessionOptions) *httpd.Mux {
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.Sessions(opts))
+	mux.Handle("/get", http.MethodGet, func(s *httpd.Store) {
+		user, _ := s.Session().Get("user")
+		s.Respond200([]byte(user))
+	})
+	mux.Handle("/login", http.MethodGet, func(s *httpd.Store) {
+		s.Session().Set("user", "alice")
+		s.Session().Renew()
+		s.Respond200([]byte("ok"))
+	})
+	mux.Handle("/silent", http.MethodGet, func(s *httpd.Store) { s.Session().Set("user", "bob") })
+	mux.Handle("/logout", http.MethodGet, func(s *httpd.Store) { s.Session().Clear() })
+	return mux
+}
+
+func TestSessions(t *testing.T) {
+	for _, opts := range []httpd.SessionOptions{
+		{Keys: [][]byte{[]byte("key1")}},
+		{Keys: [][]byte{[]byte("key1")}, Encrypt: true},
+		{Keys: [][]byte{[]byte("key1")}, Store: httpd.NewMemorySessionStore(time.Minute)},
+	} {
+		c := &sessionClient{t: t, mux: newSessionMux(opts)}
+		if body, cookie := c.do("/get"); body != "" || cookie != nil {
+			t.Fatalf("new session got %q with cookie %v, want empty without cookie", body, cookie)
+		}
+		_, cookie := c.do("/login")
+		if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge != 86400 {
+			t.Fatalf("login got cookie %v", cookie)
+		}
+		if strings.Contains(cookie.Value, "alice") {
+			t.Fatalf("login got cookie value %q, want encoded", cookie.Value)
+		}
+		if body, cookie := c.do("/get"); body != "alice" || cookie != nil {
+			t.Fatalf("saved session got %q with cookie %v, want %q without cookie", body, cookie, "alice")
+		}
+		if _, cookie := c.do("/silent"); cookie == nil {
+			t.Fatalf("handler without response should still save session")
+		}
+		if body, _ := c.do("/get"); body != "bob" {
+			t.Fatalf("saved session got %q, want %q", body, "bob")
+		}
+		if _, cookie := c.do("/logout"); cookie == nil || cookie.MaxAge >= 0 {
+			t.Fatalf("logout got cookie %v, want deleted", cookie)
+		}
+		if body, _ := c.do("/get"); body != "" {
+			t.Fatalf("cleared session got %q, want empty", body)
+		}
+
+		c.cookie = &http.Cookie{Name: "session", Value: "forged.c2lnbmF0dXJl"}
+		if body, _ := c.do("/get"); body != "" {
+			t.Fatalf("forged session got %q, want empty", body)
+		}
+	}
+}
+
+func TestSessionsKeyRotation(t *testing.T) {
+	oldMux := newSessionMux(httpd.SessionOptions{Keys: [][]byte{[]byte("old")}, Encrypt: true})
+	c := &sessionClient{t: t, mux: oldMux}
+	c.do("/login")
+
+	c.mux = newSessionMux(httpd.SessionOptions{Keys: [][]byte{[]byte("new"), []byte("old")}, Encrypt: true})
+	body, cookie := c.do("/get")
+	if body != "alice" || cookie == nil {
+		t.Fatalf("rotated session got %q with cookie %v, want %q with re-issued cookie", body, cookie, "alice")
+	}
+	c.mux = newSessionMux(httpd.SessionOptions{Keys: [][]byte{[]byte("new")}, Encrypt: true})
+	if body, _ := c.do("/get"); body != "alice" {
+		t.Fatalf("re-issued session got %q, want %q", body, "alice")
+	}
+	c.mux = oldMux
+	if body, _ := c.do("/get"); body != "" {
+		t.Fatalf("session of new key with old key got %q, want empty", body)
+	}
+}
+
+func TestSessionsExpiry(t *testing.T) {
+	store := httpd.NewMemorySessionStore(time.Millisecond)
+	c := &sessionClient{t: t, mux: newSessionMux(httpd.SessionOptions{Keys: [][]byte{[]byte("key")}, MaxAge: time.Second, Store: store})}
+	c.do("/login")
+	if body, _ := c.do("/get"); body != "alice" {
+		t.Fatalf("session got %q, want %q", body, "alice")
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if body, _ := c.do("/get"); body != "" {
+		t.Fatalf("expired session got %q, want empty", body)
+	}
+}
+
+func TestSessionsError(t *testing.T) {
+	var reported error
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.Sessions(httpd.SessionOptions{
+		Keys:    [][]byte{[]byte("key")},
+		OnError: func(s *httpd.Store, err error) { reported = err },
+	}))
+	mux.Handle("/large", http.MethodGet, func(s *httpd.Store) {
+		s.Session().Set("data", strings.Repeat("x", 4096))
+		s.Respond200([]byte("ok"))
+	})
+
+	c := &sessionClient{t: t, mux: mux}
+	if _, cookie := c.do("/large"); cookie != nil || reported == nil || !strings.Contains(reported.Error(), "exceeds 4096 bytes") {
+		t.Fatalf("large session got cookie %v and error %v, want no cookie and size error", cookie, reported)
+	}
+}
+
+func TestSessionsSetAfterClear(t *testing.T) {
+	store := httpd.NewMemorySessionStore(time.Minute)
+	mux := newSessionMux(httpd.SessionOptions{Keys: [][]byte{[]byte("key")}, Store: store})
+	mux.Handle("/switch", http.MethodGet, func(s *httpd.Store) {
+		s.Session().Clear()
+		s.Session().Set("user", "carol")
+	})
+
+	c := &sessionClient{t: t, mux: mux}
+	c.do("/login")
+	oldCookie := c.cookie
+	if _, cookie := c.do("/switch"); cookie == nil || cookie.Value == oldCookie.Value {
+		t.Fatalf("switch got cookie %v, want new cookie", cookie)
+	}
+	if body, _ := c.do("/get"); body != "carol" {
+		t.Fatalf("switched session got %q, want %q", body, "carol")
+	}
+	c.cookie = oldCookie
+	if body, _ := c.do("/get"); body != "" {
+		t.Fatalf("old session id got %q, want empty", body)
+	}
+}
@@ -0,0 +1,211 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a message of server-sent events. Multi-line Data will be split into multiple 'data' fields.
+// Line breaks in ID and Event are removed to avoid injecting fields, and so is NUL in ID.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration // reconnection time for client, ignored if zero
+}
+
+var (
+	lineBreakRemover = strings.NewReplacer("\r", "", "\n", "")
+	eventIDRemover   = strings.NewReplacer("\r", "", "\n", "", "\x00", "") // client ignores id containing NUL
+	commentReplacer  = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+)
+
+func (ev *Event) marshal() []byte {
+	var sb strings.Builder
+	if id := eventIDRemover.Replace(ev.ID); id != "" {
+		sb.WriteString("id: " + id + "\n")
+	}
+	if event := lineBreakRemover.Replace(ev.Event); event != "" {
+		sb.WriteString("event: " + event + "\n")
+	}
+	if ev.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	data := strings.ReplaceAll(strings.ReplaceAll(ev.Data, "\r\n", "\n"), "\r", "\n")
+	for line := range strings.SplitSeq(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return []byte(sb.String())
+}
+
+// EventStream writes server-sent events to client. It is safe to call its methods from multiple goroutines.
+type EventStream struct {
+	mu    sync.Mutex
+	store *Store
+	done  <-chan struct{}
+}
+
+// SSE starts an event stream by replying 200 with 'text/event-stream' content type.
+// It returns http.ErrNotSupported if response cannot be flushed.
+func (store *Store) SSE() (*EventStream, error) {
+	store.W.Header().Set("Content-Type", "text/event-stream")
+	store.W.Header().Set("Cache-Control", "no-cache")
+	store.W.Header().Set("X-Accel-Buffering", "no") // disable buffering of nginx
+	store.W.WriteHeader(http.StatusOK)
+	if err := store.W.FlushError(); err != nil {
+		return nil, err
+	}
+	return &EventStream{store: store, done: store.R.Context().Done()}, nil
+}
+
+// LastEventID returns the 'Last-Event-ID' header sent by reconnecting client.
+func (s *EventStream) LastEventID() string {
+	return s.store.R.Header.Get("Last-Event-ID")
+}
+
+// Done returns a channel that is closed when client disconnects.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes event to client and flushes it immediately.
+func (s *EventStream) Send(ev Event) error {
+	return s.write(ev.marshal())
+}
+
+// Comment writes a comment line, which is ignored by client but keeps the connection alive.
+func (s *EventStream) Comment(text string) error {
+	return s.write([]byte(": " + commentReplacer.Replace(text) + "\n\n"))
+}
+
+func (s *EventStream) write(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return s.store.R.Context().Err()
+	default:
+	}
+	if _, err := s.store.W.Write(data); err != nil {
+		return err
+	}
+	return s.store.W.FlushError()
+}
+
+// KeepAlive sends comment periodically until client disconnects or returned stop function is called.
+// The stop function must be called before handler returns, and it waits for the background goroutine to exit.
+func (s *EventStream) KeepAlive(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if s.Comment("keepalive") != nil {
+					return
+				}
+			case <-s.done:
+				return
+			case <-quit:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		<-exited
+	}
+}
+
+// EventHub broadcasts events to subscribers. Events will be dropped for slow subscribers whose buffer is full.
+type EventHub struct {
+	mu     sync.RWMutex
+	subs   map[chan Event]struct{}
+	bufLen int
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewEventHub creates a new EventHub whose subscribers have bufLen buffered events.
+func NewEventHub(bufLen int) *EventHub {
+	return &EventHub{subs: make(map[chan Event]struct{}), bufLen: bufLen, done: make(chan struct{})}
+}
+
+// Subscribe returns a channel receiving published events and a function to unsubscribe.
+func (h *EventHub) Subscribe() (events <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, h.bufLen)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish sends event to all subscribers without blocking.
+func (h *EventHub) Publish(ev Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default: // drop event for slow subscriber
+		}
+	}
+}
+
+// Len returns the number of subscribers.
+func (h *EventHub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Close ends all event streams started by Serve, e.g. in Server.OnShutdown() hook. Published events are still
+// delivered to subscribers of Subscribe.
+func (h *EventHub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+// Serve starts an event stream and forwards published events to client until client disconnects or hub is closed.
+// Keepalive comments are sent if keepAlive is greater than zero.
+func (h *EventHub) Serve(store *Store, keepAlive time.Duration) error {
+	stream, err := store.SSE()
+	if err != nil {
+		return err
+	}
+	events, unsubscribe := h.Subscribe()
+	defer unsubscribe()
+	if keepAlive > 0 {
+		defer stream.KeepAlive(keepAlive)()
+	}
+
+	for {
+		select {
+		case ev := <-events:
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		case <-stream.Done():
+			return nil
+		case <-h.done:
+			return nil
+		}
+	}
+}
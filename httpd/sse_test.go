@@ -0,0 +1,107 @@
+package httpd_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestSSE(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.Handle("/events", http.MethodGet, func(s *httpd.Store) {
+		stream, err := s.SSE()
+		if err != nil {
+			t.Errorf("SSE: %v", err)
+			return
+		}
+		stream.Send(httpd.Event{ID: "1", Event: "greet", Data: "hello\nworld", Retry: time.Second})
+		stream.Send(httpd.Event{Data: "last-id=" + stream.LastEventID()})
+		stream.Send(httpd.Event{ID: "2\x00\ndata: fake", Event: "a\r\nretry: 1\rb", Data: "x"})
+		stream.Comment("bye\r\nevent: fake")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set("Last-Event-ID", "0")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	want := "id: 1\nevent: greet\nretry: 1000\ndata: hello\ndata: world\n\ndata: last-id=0\n\nid: 2data: fake\nevent: aretry: 1b\ndata: x\n\n: bye event: fake\n\n"
+	if w.Code != 200 || w.Header().Get("Content-Type") != "text/event-stream" || w.Body.String() != want {
+		t.Fatalf("SSE response = %d %q %q, want %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String(), 200, "text/event-stream", want)
+	}
+	if !w.Flushed {
+		t.Fatal("SSE response should be flushed")
+	}
+}
+
+func TestSSENotSupported(t *testing.T) {
+	mux := httpd.NewMux()
+	mux.Handle("/events", http.MethodGet, func(s *httpd.Store) {
+		if _, err := s.SSE(); err != http.ErrNotSupported {
+			t.Fatalf("SSE() return %v, want %v", err, http.ErrNotSupported)
+		}
+	})
+	mux.ServeHTTP(&fakeResponseWriter{header: make(http.Header)}, httptest.NewRequest(http.MethodGet, "/events", nil))
+}
+
+func TestEventHub(t *testing.T) {
+	hub := httpd.NewEventHub(8)
+	mux := httpd.NewMux()
+	mux.Handle("/events", http.MethodGet, func(s *httpd.Store) { hub.Serve(s, 10*time.Millisecond) })
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var readers []*bufio.Reader
+	for range 2 {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request events: %v", err)
+		}
+		defer resp.Body.Close()
+		readers = append(readers, bufio.NewReader(resp.Body))
+	}
+	for hub.Len() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	hub.Publish(httpd.Event{Event: "progress", Data: "50%"})
+	for _, reader := range readers {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read events: %v", err)
+			}
+			if line == "\n" && len(lines) > 0 && !strings.HasPrefix(lines[0], ":") {
+				break
+			} else if line == "\n" {
+				lines = lines[:0] // skip keepalive comment
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if got := strings.Join(lines, ""); got != "event: progress\ndata: 50%\n" {
+			t.Fatalf("read event %q, want %q", got, "event: progress\ndata: 50%\n")
+		}
+	}
+
+	hub.Close() // streams end without client disconnecting
+	for _, reader := range readers {
+		if _, err := io.ReadAll(reader); err != nil {
+			t.Fatalf("read events after Close(): %v", err)
+		}
+	}
+	for hub.Len() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
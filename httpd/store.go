@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/whoisnian/glb/util/netutil"
@@ -30,6 +31,9 @@ func (ps *Params) Get(key string) (value string, ok bool) {
 type ResponseWriter struct {
 	Origin http.ResponseWriter
 	Status int
+
+	discard      bool     // discard response body for `HEAD` requests answered by `GET` handler
+	beforeHeader []func() // called once before response header is written, e.g. to save session
 }
 
 func (w *ResponseWriter) Header() http.Header {
@@ -40,10 +44,20 @@ func (w *ResponseWriter) Write(bytes []byte) (int, error) {
 	if w.Status == 0 {
 		w.WriteHeader(http.StatusOK)
 	}
+	if w.discard {
+		return len(bytes), nil
+	}
 	return w.Origin.Write(bytes)
 }
 
 func (w *ResponseWriter) WriteHeader(code int) {
+	if w.Status == 0 && len(w.beforeHeader) > 0 {
+		hooks := w.beforeHeader
+		w.beforeHeader = nil
+		for _, hook := range hooks {
+			hook()
+		}
+	}
 	w.Origin.WriteHeader(code)
 	w.Status = code
 }
@@ -86,11 +100,22 @@ type Store struct {
 	P *Params
 	I *RouteInfo
 
-	mwIndex int
+	mux           *Mux
+	mwIndex       int
+	aborted       bool
+	afterResponse []func()
+	keys          []string
+	values        []any
+	session       *Session
+	csrfToken     func() string
 }
 
 // Next should be used only in middleware to call the next middleware or handler.
+// It does nothing after Abort() is called.
 func (store *Store) Next() {
+	if store.aborted {
+		return
+	}
 	store.mwIndex++
 	if store.mwIndex < len(*store.I.Middlewares) {
 		(*store.I.Middlewares)[store.mwIndex](store)
@@ -99,6 +124,39 @@ func (store *Store) Next() {
 	}
 }
 
+// serve calls the middleware chain, and then the after-response hooks even if the chain panics.
+func (store *Store) serve() {
+	defer func() {
+		for _, hook := range store.afterResponse {
+			hook()
+		}
+	}()
+	store.Next()
+}
+
+// Abort prevents the remaining middlewares and handler from being called by Next().
+// The current middleware still continues, and the previous middlewares still run their code after Next().
+func (store *Store) Abort() {
+	store.aborted = true
+}
+
+// AbortWithStatus writes status code of response and calls Abort().
+func (store *Store) AbortWithStatus(code int) {
+	store.W.WriteHeader(code)
+	store.Abort()
+}
+
+// IsAborted reports whether Abort() has been called.
+func (store *Store) IsAborted() bool {
+	return store.aborted
+}
+
+// OnAfterResponse registers hook to be called in registration order after the middleware chain returns,
+// even if a middleware or handler panics. It's useful for releasing resources or recording metrics.
+func (store *Store) OnAfterResponse(hook func()) {
+	store.afterResponse = append(store.afterResponse, hook)
+}
+
 type HandlerFunc func(*Store)
 
 // CreateHandler converts 'http.HandlerFunc' to 'httpd.HandlerFunc'.
@@ -113,7 +171,13 @@ func CreateHandler(httpHandler http.HandlerFunc) HandlerFunc {
 //   - Request.RemoteAddr
 //
 // Warning: Malicious clients may use request header for IP spoofing.
+// Use Mux.SetTrustedProxies() to honor forwarding headers only from trusted proxies.
 func (store *Store) GetClientIP() string {
+	if store.mux != nil {
+		if trusted := store.mux.trustedProxies.Load(); trusted != nil {
+			return trustedClientIP(store.R, trusted)
+		}
+	}
 	if ip := store.R.Header.Get("X-Client-IP"); ip != "" {
 		return ip
 	}
@@ -136,6 +200,19 @@ func (store *Store) RouteParam(name string) (value string) {
 	return value
 }
 
+// RouteParamInt returns the value of specified route param as int.
+// It never fails for route param with constraint `<int>`.
+func (store *Store) RouteParamInt(name string) (int, error) {
+	return strconv.Atoi(store.RouteParam(name))
+}
+
+// RouteParamUint returns the value of specified route param as uint.
+// It never fails for route param with constraint `<uint>`.
+func (store *Store) RouteParamUint(name string) (uint, error) {
+	v, err := strconv.ParseUint(store.RouteParam(name), 10, 0)
+	return uint(v), err
+}
+
 // RouteParamAny returns the value of route param "/*".
 func (store *Store) RouteParamAny() string {
 	return store.RouteParam(routeParamAny)
@@ -176,6 +253,11 @@ func (store *Store) Error404(msg string) {
 	http.Error(store.W, msg, http.StatusNotFound)
 }
 
+// Error405 is similar to `http.Error()`.
+func (store *Store) Error405(msg string) {
+	http.Error(store.W, msg, http.StatusMethodNotAllowed)
+}
+
 // Error500 is similar to `http.Error()`.
 func (store *Store) Error500(msg string) {
 	http.Error(store.W, msg, http.StatusInternalServerError)
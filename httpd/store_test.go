@@ -6,6 +6,9 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/whoisnian/glb/httpd"
@@ -109,6 +112,81 @@ func TestCreateHandler(t *testing.T) {
 	httpd.CreateHandler(httpHandler)(store)
 }
 
+func TestAbort(t *testing.T) {
+	var trace []string
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(func(s *httpd.Store) {
+		trace = append(trace, "outer")
+		s.Next()
+		trace = append(trace, "outer-after")
+	}, func(s *httpd.Store) {
+		if s.R.URL.Query().Has("deny") {
+			s.AbortWithStatus(http.StatusForbidden)
+		}
+		s.Next()
+		trace = append(trace, "check-after")
+	}, func(s *httpd.Store) {
+		trace = append(trace, "inner")
+		s.Next()
+	})
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) {
+		trace = append(trace, "handler")
+		if s.IsAborted() {
+			t.Errorf("IsAborted() in handler = true, want false")
+		}
+	})
+
+	tests := []struct {
+		url  string
+		code int
+		want string
+	}{
+		{"/", 200, "outer,inner,handler,check-after,outer-after"},
+		{"/?deny", 403, "outer,check-after,outer-after"},
+	}
+	for _, tt := range tests {
+		trace = nil
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if got := strings.Join(trace, ","); w.Code != tt.code || got != tt.want {
+			t.Fatalf("GET %s return %d with trace %q, want %d %q", tt.url, w.Code, got, tt.code, tt.want)
+		}
+	}
+}
+
+func TestOnAfterResponse(t *testing.T) {
+	var trace []string
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(func(s *httpd.Store) {
+		s.OnAfterResponse(func() { trace = append(trace, "first:"+strconv.Itoa(s.W.Status)) })
+		s.OnAfterResponse(func() { trace = append(trace, "second") })
+		s.Next()
+	})
+	mux.Handle("/ok", http.MethodGet, func(s *httpd.Store) {
+		s.W.WriteHeader(http.StatusCreated)
+		trace = append(trace, "handler")
+	})
+	mux.Handle("/panic", http.MethodGet, func(s *httpd.Store) { panic("expected") })
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if got := strings.Join(trace, ","); got != "handler,first:201,second" {
+		t.Fatalf("trace = %q, want %q", got, "handler,first:201,second")
+	}
+
+	trace = nil
+	func() {
+		defer func() {
+			if err := recover(); err != "expected" {
+				t.Fatalf("recover() = %v, want %q", err, "expected")
+			}
+		}()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}()
+	if got := strings.Join(trace, ","); got != "first:0,second" {
+		t.Fatalf("trace after panic = %q, want %q", got, "first:0,second")
+	}
+}
+
 func TestGetClientIP(t *testing.T) {
 	store := &httpd.Store{W: &httpd.ResponseWriter{}, R: &http.Request{Header: make(http.Header)}}
 	if got := store.GetClientIP(); got != "" {
@@ -141,6 +219,25 @@ func TestGetClientIP(t *testing.T) {
 	}
 }
 
+func TestRouteParamInt(t *testing.T) {
+	store := &httpd.Store{P: &httpd.Params{K: []string{"id", "neg", "name"}, V: []string{"10", "-1", "tom"}}}
+	if v, err := store.RouteParamInt("id"); err != nil || v != 10 {
+		t.Fatalf("RouteParamInt(%q) = %d %v, want %d nil", "id", v, err, 10)
+	}
+	if v, err := store.RouteParamInt("neg"); err != nil || v != -1 {
+		t.Fatalf("RouteParamInt(%q) = %d %v, want %d nil", "neg", v, err, -1)
+	}
+	if _, err := store.RouteParamInt("name"); err == nil {
+		t.Fatalf("RouteParamInt(%q) should fail", "name")
+	}
+	if v, err := store.RouteParamUint("id"); err != nil || v != 10 {
+		t.Fatalf("RouteParamUint(%q) = %d %v, want %d nil", "id", v, err, 10)
+	}
+	if _, err := store.RouteParamUint("neg"); err == nil {
+		t.Fatalf("RouteParamUint(%q) should fail", "neg")
+	}
+}
+
 func TestCookieValue(t *testing.T) {
 	tests := []struct {
 		k, v string
@@ -228,6 +325,17 @@ func TestError404(t *testing.T) {
 	}
 }
 
+func TestError405(t *testing.T) {
+	w := &fakeResponseWriter{header: make(http.Header)}
+	store := &httpd.Store{W: &httpd.ResponseWriter{Origin: w}}
+
+	msg := "TestError405"
+	store.Error405(msg)
+	if w.code != http.StatusMethodNotAllowed || w.buf.String() != msg+"\n" {
+		t.Fatalf("Error405(msg) = %d %s, want %d %s", w.code, w.buf.String(), http.StatusMethodNotAllowed, msg)
+	}
+}
+
 func TestError500(t *testing.T) {
 	w := &fakeResponseWriter{header: make(http.Header)}
 	store := &httpd.Store{W: &httpd.ResponseWriter{Origin: w}}
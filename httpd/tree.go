@@ -2,9 +2,11 @@ package httpd
 
 import (
 	"errors"
+	"net/http"
 	"reflect"
 	"runtime"
 	"slices"
+	"strings"
 )
 
 const (
@@ -16,6 +18,7 @@ const (
 type RouteInfo struct {
 	Path        string
 	Method      string
+	Name        string // optional name for reverse URL generation, see Mux.URL()
 	HandlerName string
 	HandlerFunc HandlerFunc
 	Middlewares *[]HandlerFunc
@@ -39,6 +42,11 @@ type treeNode struct {
 	next          map[string]*treeNode
 	info          *RouteInfo
 	paramNameList []string
+	allow         string         // value of 'Allow' header, only for node with method children
+	chain         *[]HandlerFunc // middlewares of the first route, used by 405 and `OPTIONS` replies of the path
+
+	constraint  func(string) bool // only for constrained param node
+	constrained []string          // keys of constrained param children in registration order
 }
 
 func (node *treeNode) nextNodeOrNew(name string) (resNode *treeNode) {
@@ -54,13 +62,38 @@ func (node *treeNode) nextNodeOrNew(name string) (resNode *treeNode) {
 	return node.next[name]
 }
 
+// methodNodeOrNil looks for method node by the following order:
+//   - explicit method
+//   - `GET` if method is `HEAD`
+//   - MethodAll
 func (node *treeNode) methodNodeOrNil(method string) (resNode *treeNode) {
 	if resNode, ok := node.next[methodTagMap[method]]; ok {
 		return resNode
 	}
+	if method == http.MethodHead {
+		if resNode, ok := node.next[methodTagMap[http.MethodGet]]; ok {
+			return resNode
+		}
+	}
 	return node.next[methodTagMap[MethodAll]]
 }
 
+// constrainedNodeOrNew returns the constrained param child, which will be matched before `/:param` in registration order.
+func (node *treeNode) constrainedNodeOrNew(constraint string) (resNode *treeNode, err error) {
+	key := routeParam + "<" + constraint + ">"
+	if resNode, ok := node.next[key]; ok {
+		return resNode, nil
+	}
+	check, err := compileConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+	resNode = node.nextNodeOrNew(key)
+	resNode.constraint = check
+	node.constrained = append(node.constrained, key)
+	return resNode, nil
+}
+
 func parseRoute(node *treeNode, path string, method string, info *RouteInfo) (paramsCnt int, err error) {
 	methodTag, ok := methodTagMap[method]
 	if !ok {
@@ -80,12 +113,16 @@ func parseRoute(node *treeNode, path string, method string, info *RouteInfo) (pa
 			node = node.nextNodeOrNew(routeParamAny)
 			break
 		} else if path[left+1] == ':' {
-			paramName := path[left+2 : right]
-			if paramName == "" || slices.Contains(paramNameList, paramName) {
-				return 0, errors.New("invalid fragment :" + paramName + " in routePath: " + path)
+			paramName, constraint, ok := parseParamFragment(path[left+1 : right])
+			if !ok || slices.Contains(paramNameList, paramName) {
+				return 0, errors.New("invalid fragment " + path[left+1:right] + " in routePath: " + path)
 			}
 			paramNameList = append(paramNameList, paramName)
-			node = node.nextNodeOrNew(routeParam)
+			if constraint == "" {
+				node = node.nextNodeOrNew(routeParam)
+			} else if node, err = node.constrainedNodeOrNew(constraint); err != nil {
+				return 0, errors.New("invalid constraint <" + constraint + "> in routePath: " + path + ": " + err.Error())
+			}
 		} else {
 			node = node.nextNodeOrNew(path[left+1 : right])
 		}
@@ -95,54 +132,120 @@ func parseRoute(node *treeNode, path string, method string, info *RouteInfo) (pa
 	if _, ok = node.next[methodTag]; ok {
 		return 0, errors.New("duplicate method " + method + " for routePath: " + path)
 	}
-	node = node.nextNodeOrNew(methodTag)
+	node.nextNodeOrNew(methodTag)
+	node.allow = node.allowedMethods()
+	if node.chain == nil && info != nil {
+		node.chain = info.Middlewares
+	}
+	node = node.next[methodTag]
 	node.info = info
 	node.paramNameList = paramNameList
 	return len(paramNameList), nil
 }
 
+func (node *treeNode) allowedMethods() string {
+	if _, ok := node.next[methodTagMap[MethodAll]]; ok {
+		return strings.Join(methodList, ", ")
+	}
+	var allow []string
+	for _, method := range methodList {
+		if _, ok := node.next[methodTagMap[method]]; ok {
+			allow = append(allow, method)
+		} else if method == http.MethodHead && node.next[methodTagMap[http.MethodGet]] != nil {
+			allow = append(allow, method) // answered by GET handler
+		} else if method == http.MethodOptions {
+			allow = append(allow, method) // answered by Mux automatically
+		}
+	}
+	return strings.Join(allow, ", ")
+}
+
 // about trailing slash:
 //
 //	`/foo/bar`  will be matched by `/foo/bar`
 //	`/foo/bar/` will be matched by `/foo/bar/:param` or `/foo/bar/*`
 //	`/`         will be matched by `/` first and then `/:param` or `/*`
-func findRoute(node *treeNode, path string, method string, params *Params) (info *RouteInfo) {
-	var length, left, right int = len(path), 0, 0
-	if length == 1 {
-		if n := node.methodNodeOrNil(method); n != nil {
-			// if `/` is matched by `/`, skip `/:param` and `/*`
-			params.K = n.paramNameList
-			return n.info
+//
+// about priority:
+//
+//	fragment is matched by static child first, then `/:param<constraint>` in registration order, then `/:param`, and then `/*`.
+//	If the remaining fragments fail to match in one child, the next child will be tried (backtracking).
+//	A route is matched only if both path and method are matched, e.g. `POST /foo/bar` prefers `POST /foo/:param` to `GET /foo/bar`.
+//
+// If path is matched but method is not, the allowed methods and middleware chain of the first matched path will be returned.
+func findRoute(node *treeNode, path string, method string, params *Params) (info *RouteInfo, allow string, chain *[]HandlerFunc) {
+	var res, allowNode *treeNode
+	if len(path) == 1 {
+		// if `/` is matched by `/`, skip `/:param` and `/*`
+		res = node.methodNode(method, &allowNode)
+	}
+	if res == nil {
+		res = node.lookup(path, 0, method, params, &allowNode)
+	}
+	if res == nil {
+		if allowNode != nil {
+			return nil, allowNode.allow, allowNode.chain
 		}
+		return nil, "", nil
 	}
-	for ; right <= length; right++ {
-		if right < length && path[right] != '/' {
-			continue
+	params.K = res.paramNameList
+	return res.info, "", nil
+}
+
+// lookup matches the remaining path[left:] from node, where path[left] is '/' or left is len(path).
+// Param values of failed children are removed from params before trying the next child.
+func (node *treeNode) lookup(path string, left int, method string, params *Params, allowNode **treeNode) *treeNode {
+	length := len(path)
+	if left >= length {
+		return node.methodNode(method, allowNode)
+	}
+	right := left + 1
+	for right < length && path[right] != '/' {
+		right++
+	}
+	if right-left < 2 && right < length { // check routeParam if current is last fragment
+		return node.lookup(path, right, method, params, allowNode) // skip empty fragment
+	}
+
+	if next, ok := node.next[path[left+1:right]]; ok {
+		if res := next.lookup(path, right, method, params, allowNode); res != nil {
+			return res
 		}
-		if right-left < 2 && right < length { // check routeParam if current is last fragment
-			// skip empty fragment
-		} else if res, ok := node.next[path[left+1:right]]; ok {
-			node = res
-		} else if res, ok := node.next[routeParam]; ok {
-			i := len(params.V)
-			params.V = params.V[:i+1]
-			params.V[i] = path[left+1 : right]
-			node = res
-		} else if res, ok := node.next[routeParamAny]; ok {
-			i := len(params.V)
-			params.V = params.V[:i+1]
-			params.V[i] = path[left+1:]
-			node = res
-			break
-		} else {
-			return nil
+	}
+	i := len(params.V)
+	for _, key := range node.constrained {
+		if next := node.next[key]; next.constraint(path[left+1 : right]) {
+			params.V = append(params.V, path[left+1:right])
+			if res := next.lookup(path, right, method, params, allowNode); res != nil {
+				return res
+			}
+			params.V = params.V[:i]
 		}
-		left = right
 	}
-	if node = node.methodNodeOrNil(method); node != nil {
-		params.K = node.paramNameList
-		return node.info
-	} else {
-		return nil
+	if next, ok := node.next[routeParam]; ok {
+		params.V = append(params.V, path[left+1:right])
+		if res := next.lookup(path, right, method, params, allowNode); res != nil {
+			return res
+		}
+		params.V = params.V[:i]
+	}
+	if next, ok := node.next[routeParamAny]; ok {
+		params.V = append(params.V, path[left+1:])
+		if res := next.methodNode(method, allowNode); res != nil {
+			return res
+		}
+		params.V = params.V[:i]
+	}
+	return nil
+}
+
+// methodNode returns the method node of matched path, or records the first matched path as allowNode if method is not matched.
+func (node *treeNode) methodNode(method string, allowNode **treeNode) *treeNode {
+	if res := node.methodNodeOrNil(method); res != nil {
+		return res
+	}
+	if *allowNode == nil && node.allow != "" {
+		*allowNode = node
 	}
+	return nil
 }
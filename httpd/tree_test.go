@@ -3,6 +3,7 @@ package httpd
 import (
 	"net/http"
 	"net/url"
+	"slices"
 	"testing"
 )
 
@@ -52,7 +53,7 @@ func TestRoute(t *testing.T) {
 			method = http.MethodConnect
 		}
 		params.V = params.V[:0]
-		info := findRoute(root, u.Path, method, &params)
+		info, _, _ := findRoute(root, u.Path, method, &params)
 		if info == nil {
 			t.Fatalf("routeInfo for %q not found", tt.url)
 		}
@@ -100,7 +101,7 @@ func TestRouteParam(t *testing.T) {
 		}
 
 		params.V = params.V[:0]
-		info := findRoute(root, u.Path, tt.method, &params)
+		info, _, _ := findRoute(root, u.Path, tt.method, &params)
 		if info == nil {
 			t.Fatalf("routeInfo for %q not found", tt.url)
 		}
@@ -119,3 +120,155 @@ func TestRouteParam(t *testing.T) {
 		}
 	}
 }
+
+func TestRouteBacktracking(t *testing.T) {
+	routes := []struct {
+		path   string
+		method string
+	}{
+		{"/files/new/edit", http.MethodGet},
+		{"/files/:id/view", http.MethodGet},
+		{"/files/*", http.MethodGet},
+		{"/aaa/bbb", http.MethodGet},
+		{"/aaa/:id", http.MethodPost},
+		{"/ccc/:id/ddd/eee", http.MethodGet},
+		{"/ccc/ddd/:id/fff", http.MethodGet},
+		{"/ggg/:id", http.MethodGet},
+		{"/ggg/*", http.MethodGet},
+		{"/hhh/iii/:id", http.MethodGet},
+		{"/*", http.MethodGet},
+	}
+	tests := []struct {
+		url    string
+		method string
+		path   string
+		paramV []string
+	}{
+		{"/files/new/edit", http.MethodGet, "/files/new/edit", nil},
+		{"/files/new/view", http.MethodGet, "/files/:id/view", []string{"new"}},
+		{"/files/10/view", http.MethodGet, "/files/:id/view", []string{"10"}},
+		{"/files/new/other", http.MethodGet, "/files/*", []string{"new/other"}},
+		{"/files/new", http.MethodGet, "/files/*", []string{"new"}},
+		{"/aaa/bbb", http.MethodGet, "/aaa/bbb", nil},
+		{"/aaa/bbb", http.MethodPost, "/aaa/:id", []string{"bbb"}},
+		{"/ccc/ddd/ddd/eee", http.MethodGet, "/ccc/:id/ddd/eee", []string{"ddd"}},
+		{"/ccc/ddd/10/fff", http.MethodGet, "/ccc/ddd/:id/fff", []string{"10"}},
+		{"/ggg/10", http.MethodGet, "/ggg/:id", []string{"10"}},
+		{"/ggg/", http.MethodGet, "/ggg/:id", []string{""}},
+		{"/ggg/10/20", http.MethodGet, "/ggg/*", []string{"10/20"}},
+		{"/hhh/iii", http.MethodGet, "/*", []string{"hhh/iii"}},
+		{"/hhh/iii/10/20", http.MethodGet, "/*", []string{"hhh/iii/10/20"}},
+		{"/ccc/10/ddd", http.MethodGet, "/*", []string{"ccc/10/ddd"}},
+	}
+
+	root := new(treeNode)
+	for _, tt := range routes {
+		info := newRouteInfo(tt.path, tt.method, func(*Store) {}, &[]HandlerFunc{})
+		if _, err := parseRoute(root, tt.path, tt.method, info); err != nil {
+			t.Fatalf("parseRoute: %v", err)
+		}
+	}
+
+	for _, tt := range tests {
+		u, err := url.ParseRequestURI(tt.url)
+		if err != nil {
+			t.Fatalf("ParseRequestURI: %v", err)
+		}
+
+		params := Params{}
+		info, _, _ := findRoute(root, u.Path, tt.method, &params)
+		if info == nil {
+			t.Fatalf("routeInfo for %q %q not found", tt.method, tt.url)
+		}
+		if info.Path != tt.path || !slices.Equal(params.V, tt.paramV) || len(params.K) != len(params.V) {
+			t.Fatalf("url %q match %q %q, want %q %q", tt.url, info.Path, params.V, tt.path, tt.paramV)
+		}
+	}
+}
+
+func TestRouteAllow(t *testing.T) {
+	routes := []struct {
+		path   string
+		method string
+	}{
+		{"/files/new/edit", http.MethodPut},
+		{"/files/:id/edit", http.MethodPost},
+		{"/files/*", http.MethodDelete},
+	}
+	tests := []struct {
+		url    string
+		method string
+		allow  string
+	}{
+		{"/files/new/edit", http.MethodGet, "PUT, OPTIONS"},
+		{"/files/10/edit", http.MethodGet, "POST, OPTIONS"},
+		{"/files/10/view", http.MethodGet, "DELETE, OPTIONS"},
+		{"/other", http.MethodGet, ""},
+	}
+
+	root := new(treeNode)
+	for _, tt := range routes {
+		info := newRouteInfo(tt.path, tt.method, func(*Store) {}, &[]HandlerFunc{})
+		if _, err := parseRoute(root, tt.path, tt.method, info); err != nil {
+			t.Fatalf("parseRoute: %v", err)
+		}
+	}
+
+	for _, tt := range tests {
+		params := Params{}
+		info, allow, _ := findRoute(root, tt.url, tt.method, &params)
+		if info != nil || allow != tt.allow || len(params.V) != 0 {
+			t.Fatalf("url %q match %v %q %q, want nil %q", tt.url, info, allow, params.V, tt.allow)
+		}
+	}
+}
+
+func TestRouteConstraint(t *testing.T) {
+	routes := []struct {
+		path   string
+		method string
+	}{
+		{"/users/:id<int>", http.MethodGet},
+		{"/users/:uuid<uuid>", http.MethodGet},
+		{"/users/:name", http.MethodGet},
+		{"/users/me", http.MethodGet},
+		{"/posts/:slug<[a-z0-9-]+>/edit", http.MethodGet},
+		{"/posts/:id<int>", http.MethodGet},
+		{"/posts/*", http.MethodGet},
+	}
+	tests := []struct {
+		url    string
+		path   string
+		paramV []string
+	}{
+		{"/users/10", "/users/:id<int>", []string{"10"}},
+		{"/users/123e4567-e89b-12d3-a456-426614174000", "/users/:uuid<uuid>", []string{"123e4567-e89b-12d3-a456-426614174000"}},
+		{"/users/tom", "/users/:name", []string{"tom"}},
+		{"/users/me", "/users/me", nil},
+		{"/users/", "/users/:name", []string{""}},
+		{"/posts/hello-world/edit", "/posts/:slug<[a-z0-9-]+>/edit", []string{"hello-world"}},
+		{"/posts/Hello/edit", "/posts/*", []string{"Hello/edit"}},
+		{"/posts/10", "/posts/:id<int>", []string{"10"}},
+		{"/posts/10/edit", "/posts/:slug<[a-z0-9-]+>/edit", []string{"10"}},
+		{"/posts/hello", "/posts/*", []string{"hello"}},
+	}
+
+	root := new(treeNode)
+	for _, tt := range routes {
+		info := newRouteInfo(tt.path, tt.method, func(*Store) {}, &[]HandlerFunc{})
+		if _, err := parseRoute(root, tt.path, tt.method, info); err != nil {
+			t.Fatalf("parseRoute: %v", err)
+		}
+	}
+
+	for _, tt := range tests {
+		params := Params{}
+		info, _, _ := findRoute(root, tt.url, http.MethodGet, &params)
+		if info == nil {
+			t.Fatalf("routeInfo for %q not found", tt.url)
+		}
+		if info.Path != tt.path || !slices.Equal(params.V, tt.paramV) {
+			t.Fatalf("url %q match %q %q, want %q %q", tt.url, info.Path, params.V, tt.path, tt.paramV)
+		}
+	}
+}
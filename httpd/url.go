@@ -0,0 +1,97 @@
+package httpd
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// URL builds the path of route with given name. The params are key-value pairs to fill in route params,
+// and the key of route param `/*` is "*". Values will be escaped, but '/' in value of `/*` will be kept.
+// It returns error if value does not satisfy the constraint of route param, or name is used by multiple routes.
+//
+// Example:
+//
+//	mux.Handle("/users/:id<int>/files/*", http.MethodGet, handler).Name = "userFile"
+//	mux.URL("userFile", "id", "10", "*", "a b/c.txt") // "/users/10/files/a%20b/c.txt"
+func (mux *Mux) URL(name string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", errors.New("httpd: odd number of params for route " + name)
+	}
+
+	mux.mu.RLock()
+	var info *RouteInfo
+	var duplicate bool
+	for _, r := range mux.routes {
+		if r.Name != name {
+			continue
+		} else if info != nil {
+			duplicate = true
+			break
+		}
+		info = r
+	}
+	mux.mu.RUnlock()
+	if info == nil {
+		return "", errors.New("httpd: route " + name + " not found")
+	} else if duplicate {
+		return "", errors.New("httpd: route name " + name + " is used by multiple routes")
+	}
+	return buildPath(info.Path, params)
+}
+
+func buildPath(path string, params []string) (string, error) {
+	var sb strings.Builder
+	var used int
+	var length, left, right int = len(path), 0, 0
+	for ; right <= length; right++ {
+		if right < length && path[right] != '/' {
+			continue
+		}
+		sb.WriteString(path[left:min(left+1, right)]) // leading slash
+		if right-left < 2 {
+			// keep empty fragment
+		} else if path[left+1:right] == "*" {
+			value, ok := lookupParam(params, "*")
+			if !ok {
+				return "", errors.New("httpd: missing param * for routePath: " + path)
+			}
+			segments := strings.Split(value, "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			sb.WriteString(strings.Join(segments, "/"))
+			used++
+			break
+		} else if path[left+1] == ':' {
+			paramName, constraint, _ := parseParamFragment(path[left+1 : right])
+			value, ok := lookupParam(params, paramName)
+			if !ok {
+				return "", errors.New("httpd: missing param " + paramName + " for routePath: " + path)
+			}
+			if constraint != "" {
+				if check, err := compileConstraint(constraint); err != nil || !check(value) {
+					return "", errors.New("httpd: param " + paramName + " does not satisfy constraint <" + constraint + "> for routePath: " + path)
+				}
+			}
+			sb.WriteString(url.PathEscape(value))
+			used++
+		} else {
+			sb.WriteString(path[left+1 : right])
+		}
+		left = right
+	}
+	if used*2 != len(params) {
+		return "", errors.New("httpd: unknown or duplicate params for routePath: " + path)
+	}
+	return sb.String(), nil
+}
+
+func lookupParam(params []string, key string) (string, bool) {
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == key {
+			return params[i+1], true
+		}
+	}
+	return "", false
+}
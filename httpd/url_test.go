@@ -0,0 +1,68 @@
+package httpd_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []string
+		want   string
+	}{
+		{"root", nil, "/"},
+		{"user", []string{"id", "10"}, "/users/10"},
+		{"userFile", []string{"id", "10", "*", "a b/c?.txt"}, "/users/10/files/a%20b/c%3F.txt"},
+		{"slug", []string{"slug", "hello/world"}, "/api/posts/hello%2Fworld/"},
+	}
+
+	mux := httpd.NewMux()
+	mux.Handle("/", http.MethodGet, func(*httpd.Store) {}).Name = "root"
+	mux.Handle("/users/:id<int>", http.MethodGet, func(*httpd.Store) {}).Name = "user"
+	mux.Handle("/users/:id/files/*", http.MethodGet, func(*httpd.Store) {}).Name = "userFile"
+	mux.Group("/api").Handle("/posts/:slug/", http.MethodGet, func(*httpd.Store) {}).Name = "slug"
+
+	for _, tt := range tests {
+		got, err := mux.URL(tt.name, tt.params...)
+		if err != nil {
+			t.Fatalf("URL(%q, %q): %v", tt.name, tt.params, err)
+		}
+		if got != tt.want {
+			t.Fatalf("URL(%q, %q) = %q, want %q", tt.name, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestURLError(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []string
+	}{
+		{"unknown", nil},
+		{"user", nil},
+		{"user", []string{"id"}},
+		{"user", []string{"name", "10"}},
+		{"user", []string{"id", "10", "name", "tom"}},
+		{"userFile", []string{"id", "10"}},
+		{"post", []string{"id", "abc"}},
+		{"post", []string{"id", "-1", "slug", "hello"}},
+		{"post", []string{"id", "1", "slug", "Hello"}},
+		{"duplicate", nil},
+	}
+
+	mux := httpd.NewMux()
+	mux.Handle("/users/:id", http.MethodGet, func(*httpd.Store) {}).Name = "user"
+	mux.Handle("/users/:id/files/*", http.MethodGet, func(*httpd.Store) {}).Name = "userFile"
+	mux.Handle("/posts/:id<uint>/:slug<[a-z]+>", http.MethodGet, func(*httpd.Store) {}).Name = "post"
+	mux.Handle("/a", http.MethodGet, func(*httpd.Store) {}).Name = "duplicate"
+	mux.Handle("/b", http.MethodGet, func(*httpd.Store) {}).Name = "duplicate"
+
+	for _, tt := range tests {
+		if got, err := mux.URL(tt.name, tt.params...); err == nil {
+			t.Fatalf("URL(%q, %q) = %q, want error", tt.name, tt.params, got)
+		}
+	}
+}
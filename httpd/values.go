@@ -0,0 +1,65 @@
+package httpd
+
+import "slices"
+
+// Keys of values set by built-in middlewares.
+const (
+	KeyRequestID = "httpd.request_id" // string, set by RequestID middleware
+	KeyPrincipal = "httpd.principal"  // *Principal, set by auth middlewares
+)
+
+// Set stores a request-scoped value for later middlewares and handlers, e.g. the authenticated user or trace span.
+// Values are cleared when the request is finished.
+func (store *Store) Set(key string, value any) {
+	for i := range store.keys {
+		if store.keys[i] == key {
+			store.values[i] = value
+			return
+		}
+	}
+	store.keys = append(store.keys, key)
+	store.values = append(store.values, value)
+}
+
+// Get returns the value stored by Set. The ok result indicates whether key was found.
+func (store *Store) Get(key string) (value any, ok bool) {
+	for i := range store.keys {
+		if store.keys[i] == key {
+			return store.values[i], true
+		}
+	}
+	return nil, false
+}
+
+// Delete removes the value stored by Set.
+func (store *Store) Delete(key string) {
+	for i := range store.keys {
+		if store.keys[i] == key {
+			store.keys = slices.Delete(store.keys, i, i+1)
+			store.values = slices.Delete(store.values, i, i+1) // removed value is zeroed for GC
+			return
+		}
+	}
+}
+
+// resetValues clears values but keeps the capacity for reuse.
+func (store *Store) resetValues() {
+	clear(store.values)
+	store.keys = store.keys[:0]
+	store.values = store.values[:0]
+}
+
+// GetAs returns the value stored by Set as type T. The ok result indicates whether key was found with type T.
+func GetAs[T any](store *Store, key string) (value T, ok bool) {
+	v, found := store.Get(key)
+	value, ok = v.(T)
+	return value, ok && found
+}
+
+// GetOr returns the value stored by Set as type T, or def if key was not found with type T.
+func GetOr[T any](store *Store, key string, def T) T {
+	if value, ok := GetAs[T](store, key); ok {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,60 @@
+package httpd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func TestStoreValues(t *testing.T) {
+	store := &httpd.Store{}
+	if _, ok := store.Get("user"); ok {
+		t.Fatalf("Get() on empty store should not be found")
+	}
+	store.Set("user", "alice")
+	store.Set("count", 1)
+	store.Set("user", "bob")
+	if v, ok := store.Get("user"); !ok || v != "bob" {
+		t.Fatalf("Get(user) = %v, %v, want %v, %v", v, ok, "bob", true)
+	}
+	if v, ok := httpd.GetAs[int](store, "count"); !ok || v != 1 {
+		t.Fatalf("GetAs[int](count) = %v, %v, want %v, %v", v, ok, 1, true)
+	}
+	if v, ok := httpd.GetAs[string](store, "count"); ok || v != "" {
+		t.Fatalf("GetAs[string](count) = %q, %v, want %q, %v", v, ok, "", false)
+	}
+	if v := httpd.GetOr(store, "missing", 42); v != 42 {
+		t.Fatalf("GetOr(missing, 42) = %v, want %v", v, 42)
+	}
+	store.Delete("user")
+	if _, ok := store.Get("user"); ok {
+		t.Fatalf("Get(user) after Delete() should not be found")
+	}
+	if v, ok := store.Get("count"); !ok || v != 1 {
+		t.Fatalf("Get(count) after Delete(user) = %v, %v, want %v, %v", v, ok, 1, true)
+	}
+}
+
+func TestStoreValuesCleared(t *testing.T) {
+	var leaked []bool
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.RequestID(httpd.RequestIDOptions{}))
+	mux.Handle("/", http.MethodGet, func(s *httpd.Store) {
+		_, ok := s.Get("user")
+		leaked = append(leaked, ok)
+		s.Set("user", "alice")
+		if id, ok := httpd.GetAs[string](s, httpd.KeyRequestID); !ok || id != s.RequestID() {
+			t.Errorf("GetAs(KeyRequestID) = %q, %v, want %q, %v", id, ok, s.RequestID(), true)
+		}
+	})
+	for range 3 {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	for i, ok := range leaked {
+		if ok {
+			t.Fatalf("request #%d got value set by previous request", i)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package httpd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types defined in RFC 6455, section 11.8.
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// Close codes defined in RFC 6455, section 11.7.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011
+)
+
+const (
+	websocketGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultMaxMessageSize = 32 << 20
+	maxControlPayload     = 125
+)
+
+var (
+	ErrWebSocketHandshake = errors.New("httpd: bad websocket handshake")
+	ErrWebSocketCloseSent = errors.New("httpd: websocket close frame has been sent")
+)
+
+// flateTail is appended to compressed message before decompression, see RFC 7692, section 7.2.2.
+var flateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// WebSocketOptions configures both the server side Store.UpgradeWebSocket() and the client side DialWebSocket().
+type WebSocketOptions struct {
+	Subprotocols      []string                 // supported subprotocols in order of preference
+	MaxMessageSize    int64                    // max size of received message after decompression, default 32 MiB
+	FragmentSize      int                      // split written message into frames of this size if greater than zero
+	EnableCompression bool                     // negotiate permessage-deflate extension without context takeover
+	PongHandler       func(appData []byte)     // called for received pong frames in ReadMessage()
+	CheckOrigin       func(*http.Request) bool // server only, default allows requests without 'Origin' or from the same host
+	Header            http.Header              // client only, additional request headers for handshake
+	TLSConfig         *tls.Config              // client only, used for 'wss://' urls
+}
+
+// CloseError is returned by WebSocketConn.ReadMessage() when a close frame is received.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "httpd: websocket closed with code " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// WebSocketConn is a websocket connection. ReadMessage() should be called from one goroutine,
+// while other write methods are safe to be called concurrently.
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+	compress    bool
+	opts        WebSocketOptions
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, isServer bool, subprotocol string, compress bool, opts *WebSocketOptions) *WebSocketConn {
+	c := &WebSocketConn{conn: conn, br: br, isServer: isServer, subprotocol: subprotocol, compress: compress}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.MaxMessageSize <= 0 {
+		c.opts.MaxMessageSize = defaultMaxMessageSize
+	}
+	return c
+}
+
+func computeAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerTokens returns comma-separated tokens in header values.
+func headerTokens(h http.Header, name string) (tokens []string) {
+	for _, v := range h.Values(name) {
+		for token := range strings.SplitSeq(v, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(h http.Header, name string, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptDeflateOffer reports whether one of permessage-deflate offers can be accepted without context takeover.
+func acceptDeflateOffer(h http.Header) bool {
+	for _, offer := range headerTokens(h, "Sec-WebSocket-Extensions") {
+		name, params, _ := strings.Cut(offer, ";")
+		if strings.TrimSpace(name) != "permessage-deflate" {
+			continue
+		}
+		ok := true
+		for param := range strings.SplitSeq(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "server_max_window_bits" && strings.Trim(value, `"`) != "15" {
+				ok = false // compress/flate always uses 32K window
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// UpgradeWebSocket upgrades the HTTP connection to a websocket connection with RFC 6455 handshake.
+// Error response is sent to client if handshake fails. The connection is hijacked, so handler must not
+// write to store.W after upgrading, and store.W.Status is set to 101 for logging.
+func (store *Store) UpgradeWebSocket(opts *WebSocketOptions) (*WebSocketConn, error) {
+	if opts == nil {
+		opts = &WebSocketOptions{}
+	}
+	r := store.R
+	if r.Method != http.MethodGet || !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(store.W, "400 bad websocket handshake", http.StatusBadRequest)
+		return nil, ErrWebSocketHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		store.W.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(store.W, "426 unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, ErrWebSocketHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(store.W, "400 bad websocket key", http.StatusBadRequest)
+		return nil, ErrWebSocketHandshake
+	}
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(store.W, "403 websocket origin not allowed", http.StatusForbidden)
+		return nil, ErrWebSocketHandshake
+	}
+
+	var subprotocol string
+	for _, p := range headerTokens(r.Header, "Sec-WebSocket-Protocol") {
+		if subprotocol == "" && slices.Contains(opts.Subprotocols, p) {
+			subprotocol = p
+		}
+	}
+	compress := opts.EnableCompression && acceptDeflateOffer(r.Header)
+
+	conn, brw, err := store.W.Hijack()
+	if err != nil {
+		store.Error500("500 websocket not supported")
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	sb.WriteString("Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		sb.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	sb.WriteString("\r\n")
+	if _, err = conn.Write([]byte(sb.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	store.W.Status = http.StatusSwitchingProtocols
+	return newWebSocketConn(conn, brw.Reader, true, subprotocol, compress, opts), nil
+}
+
+// DialWebSocket connects to a websocket server at rawURL ('ws://', 'wss://', 'http://' or 'https://').
+// The deadline of ctx applies to the handshake.
+func DialWebSocket(ctx context.Context, rawURL string, opts *WebSocketOptions) (*WebSocketConn, error) {
+	if opts == nil {
+		opts = &WebSocketOptions{}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var useTLS bool
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme, useTLS = "https", true
+	default:
+		return nil, errors.New("httpd: unsupported websocket url scheme " + u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" && useTLS {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	} else if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if useTLS {
+		cfg := opts.TLSConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	c, err := clientHandshake(conn, u, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+func clientHandshake(conn net.Conn, u *url.URL, opts *WebSocketOptions) (*WebSocketConn, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce) // crypto/rand.Read() never returns an error
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Host: u.Host, Header: make(http.Header)}
+	for k, v := range opts.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(opts.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(opts.Subprotocols, ", "))
+	}
+	if opts.EnableCompression {
+		req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContainsToken(resp.Header, "Upgrade", "websocket") ||
+		!headerContainsToken(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != computeAcceptKey(key) {
+		return nil, ErrWebSocketHandshake
+	}
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+	if subprotocol != "" && !slices.Contains(opts.Subprotocols, subprotocol) {
+		return nil, ErrWebSocketHandshake
+	}
+	var compress bool
+	for _, ext := range headerTokens(resp.Header, "Sec-WebSocket-Extensions") {
+		name, params, _ := strings.Cut(ext, ";")
+		if !opts.EnableCompression || strings.TrimSpace(name) != "permessage-deflate" || !strings.Contains(params, "server_no_context_takeover") {
+			return nil, ErrWebSocketHandshake
+		}
+		compress = true
+	}
+	return newWebSocketConn(conn, br, false, subprotocol, compress, opts), nil
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *WebSocketConn) Subprotocol() string { return c.subprotocol }
+
+// Compressed reports whether permessage-deflate extension is negotiated.
+func (c *WebSocketConn) Compressed() bool { return c.compress }
+
+// NetConn returns the underlying connection, e.g. to set deadlines.
+func (c *WebSocketConn) NetConn() net.Conn { return c.conn }
+
+// ReadMessage reads the next text or binary message, fragmented frames are joined together.
+// Ping frames are answered with pong automatically. *CloseError is returned if a close frame is received,
+// and the close frame will be echoed if no close frame has been sent.
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	var compressed bool
+	for {
+		fin, rsv1, opcode, payload, err := c.readFrame(c.opts.MaxMessageSize - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err := c.writeControl(PongMessage, payload); err != nil && err != ErrWebSocketCloseSent {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.opts.PongHandler != nil {
+				c.opts.PongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected data frame in fragmented message")
+			}
+			messageType, compressed = opcode, rsv1
+		case continuationFrame:
+			if messageType == 0 || rsv1 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		}
+		data = append(data, payload...)
+		if fin {
+			break
+		}
+	}
+
+	if compressed {
+		r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(flateTail)))
+		data, err = io.ReadAll(io.LimitReader(r, c.opts.MaxMessageSize+1))
+		if err != nil {
+			return 0, nil, c.fail(CloseInvalidPayload, "invalid compressed message")
+		} else if int64(len(data)) > c.opts.MaxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8 text message")
+	}
+	return messageType, data, nil
+}
+
+// readFrame reads a frame whose payload of data frame should not exceed limit.
+func (c *WebSocketConn) readFrame(limit int64) (fin bool, rsv1 bool, opcode int, payload []byte, err error) {
+	var hdr [8]byte
+	if _, err = io.ReadFull(c.br, hdr[:2]); err != nil {
+		return
+	}
+	fin, rsv1, opcode = hdr[0]&0x80 != 0, hdr[0]&0x40 != 0, int(hdr[0]&0x0f)
+	masked, length := hdr[1]&0x80 != 0, int64(hdr[1]&0x7f)
+
+	switch {
+	case hdr[0]&0x30 != 0 || (rsv1 && (!c.compress || opcode >= CloseMessage)):
+		return fin, rsv1, opcode, nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+	case opcode > BinaryMessage && opcode < CloseMessage || opcode > PongMessage:
+		return fin, rsv1, opcode, nil, c.fail(CloseProtocolError, "unknown opcode "+strconv.Itoa(opcode))
+	case opcode >= CloseMessage && (!fin || length > maxControlPayload):
+		return fin, rsv1, opcode, nil, c.fail(CloseProtocolError, "invalid control frame")
+	case masked != c.isServer:
+		return fin, rsv1, opcode, nil, c.fail(CloseProtocolError, "unexpected mask bit")
+	}
+
+	if length == 126 {
+		if _, err = io.ReadFull(c.br, hdr[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(hdr[:2]))
+	} else if length == 127 {
+		if _, err = io.ReadFull(c.br, hdr[:8]); err != nil {
+			return
+		}
+		if length = int64(binary.BigEndian.Uint64(hdr[:8])); length < 0 {
+			return fin, rsv1, opcode, nil, c.fail(CloseProtocolError, "invalid payload length")
+		}
+	}
+	if opcode < CloseMessage && length > limit {
+		return fin, rsv1, opcode, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, maskKey[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(maskKey, payload)
+	}
+	return fin, rsv1, opcode, payload, nil
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+func (c *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.Valid(payload[2:]) {
+			return c.fail(CloseProtocolError, "invalid close frame")
+		}
+	} else if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close frame")
+	}
+
+	c.writeMu.Lock()
+	if !c.closeSent {
+		c.closeSent = true
+		c.writeFrame(true, false, CloseMessage, payload[:min(len(payload), 2)])
+	}
+	c.writeMu.Unlock()
+	c.conn.Close()
+	return closeErr
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// truncateUTF8 truncates s to at most n bytes without splitting a UTF-8 rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// fail sends close frame with code and closes the connection.
+func (c *WebSocketConn) fail(code int, text string) error {
+	c.Close(code, text)
+	return errors.New("httpd: websocket " + text)
+}
+
+// WriteMessage writes a text, binary, ping or pong message.
+// Text and binary messages are compressed if negotiated, and fragmented if FragmentSize is set.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage:
+		return c.writeControl(messageType, data)
+	default:
+		return errors.New("httpd: invalid websocket message type " + strconv.Itoa(messageType))
+	}
+
+	if c.compress {
+		var buf bytes.Buffer
+		fw, _ := flate.NewWriter(&buf, flate.DefaultCompression) // error only for invalid level
+		fw.Write(data)
+		fw.Flush()
+		data = buf.Bytes()[:buf.Len()-4] // remove tail 0x00 0x00 0xff 0xff
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrWebSocketCloseSent
+	}
+	opcode := messageType
+	for {
+		n := len(data)
+		if c.opts.FragmentSize > 0 {
+			n = min(n, c.opts.FragmentSize)
+		}
+		if err := c.writeFrame(n == len(data), c.compress && opcode != continuationFrame, opcode, data[:n]); err != nil {
+			return err
+		}
+		if data = data[n:]; len(data) == 0 {
+			return nil
+		}
+		opcode = continuationFrame
+	}
+}
+
+func (c *WebSocketConn) writeControl(opcode int, payload []byte) error {
+	if len(payload) > maxControlPayload {
+		return errors.New("httpd: websocket control frame payload too long")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrWebSocketCloseSent
+	}
+	return c.writeFrame(true, false, opcode, payload)
+}
+
+// writeFrame writes a single frame, and it must be called with writeMu held.
+func (c *WebSocketConn) writeFrame(fin bool, rsv1 bool, opcode int, payload []byte) error {
+	frame := make([]byte, 2, 14+len(payload))
+	frame[0] = byte(opcode)
+	if fin {
+		frame[0] |= 0x80
+	}
+	if rsv1 {
+		frame[0] |= 0x40
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		frame[1] = byte(length)
+	case length <= 0xffff:
+		frame[1] = 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame[1] = 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	if c.isServer {
+		frame = append(frame, payload...)
+	} else {
+		var maskKey [4]byte
+		rand.Read(maskKey[:]) // crypto/rand.Read() never returns an error
+		frame[1] |= 0x80
+		frame = append(append(frame, maskKey[:]...), payload...)
+		maskBytes(maskKey, frame[len(frame)-len(payload):])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame with code and reason if no close frame has been sent, and then closes the connection.
+// The reason is truncated to 123 bytes on UTF-8 rune boundary.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	c.writeMu.Lock()
+	if !c.closeSent {
+		c.closeSent = true
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, truncateUTF8(reason, maxControlPayload-2)...)
+		c.writeFrame(true, false, CloseMessage, payload)
+	}
+	c.writeMu.Unlock()
+	return c.conn.Close()
+}
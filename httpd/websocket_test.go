@@ -0,0 +1,229 @@
+package httpd_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whoisnian/glb/httpd"
+)
+
+func newWebSocketServer(t *testing.T, opts *httpd.WebSocketOptions, handler func(*httpd.WebSocketConn)) (wsURL string) {
+	t.Helper()
+	mux := httpd.NewMux()
+	mux.Handle("/ws", http.MethodGet, func(s *httpd.Store) {
+		conn, err := s.UpgradeWebSocket(opts)
+		if err != nil {
+			return
+		}
+		handler(conn)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+}
+
+func echoWebSocket(conn *httpd.WebSocketConn) {
+	defer conn.Close(httpd.CloseNormalClosure, "")
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err = conn.WriteMessage(mt, data); err != nil {
+			return
+		}
+	}
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverOpts *httpd.WebSocketOptions
+		clientOpts *httpd.WebSocketOptions
+		compressed bool
+	}{
+		{"plain", nil, nil, false},
+		{"fragmented", &httpd.WebSocketOptions{FragmentSize: 7}, &httpd.WebSocketOptions{FragmentSize: 3}, false},
+		{"compressed", &httpd.WebSocketOptions{EnableCompression: true}, &httpd.WebSocketOptions{EnableCompression: true}, true},
+		{"compressedFragmented", &httpd.WebSocketOptions{EnableCompression: true, FragmentSize: 5}, &httpd.WebSocketOptions{EnableCompression: true, FragmentSize: 2}, true},
+		{"serverOnlyCompression", &httpd.WebSocketOptions{EnableCompression: true}, nil, false},
+	}
+	messages := []struct {
+		mt   int
+		data []byte
+	}{
+		{httpd.TextMessage, []byte("hello websocket")},
+		{httpd.BinaryMessage, []byte{0, 1, 2, 3, 255}},
+		{httpd.TextMessage, []byte(strings.Repeat("large message ", 10000))},
+		{httpd.BinaryMessage, []byte{}},
+	}
+
+	for _, tt := range tests {
+		wsURL := newWebSocketServer(t, tt.serverOpts, echoWebSocket)
+		conn, err := httpd.DialWebSocket(context.Background(), wsURL, tt.clientOpts)
+		if err != nil {
+			t.Fatalf("%s: DialWebSocket: %v", tt.name, err)
+		}
+		if conn.Compressed() != tt.compressed {
+			t.Fatalf("%s: Compressed() = %v, want %v", tt.name, conn.Compressed(), tt.compressed)
+		}
+		for _, msg := range messages {
+			if err = conn.WriteMessage(msg.mt, msg.data); err != nil {
+				t.Fatalf("%s: WriteMessage: %v", tt.name, err)
+			}
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("%s: ReadMessage: %v", tt.name, err)
+			}
+			if mt != msg.mt || !bytes.Equal(data, msg.data) {
+				t.Fatalf("%s: ReadMessage() = %d %.20q, want %d %.20q", tt.name, mt, data, msg.mt, msg.data)
+			}
+		}
+		conn.Close(httpd.CloseNormalClosure, "")
+	}
+}
+
+func TestWebSocketPingClose(t *testing.T) {
+	serverErr := make(chan error, 1)
+	wsURL := newWebSocketServer(t, nil, func(conn *httpd.WebSocketConn) {
+		_, _, err := conn.ReadMessage()
+		serverErr <- err
+	})
+
+	pong := make(chan string, 1)
+	conn, err := httpd.DialWebSocket(context.Background(), wsURL, &httpd.WebSocketOptions{
+		PongHandler: func(appData []byte) { pong <- string(appData) },
+	})
+	if err != nil {
+		t.Fatalf("DialWebSocket: %v", err)
+	}
+	if err = conn.WriteMessage(httpd.PingMessage, []byte("ping")); err != nil {
+		t.Fatalf("WriteMessage(ping): %v", err)
+	}
+	go conn.ReadMessage()
+	select {
+	case data := <-pong:
+		if data != "ping" {
+			t.Fatalf("pong data = %q, want %q", data, "ping")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pong not received")
+	}
+
+	conn.Close(httpd.CloseGoingAway, "bye")
+	var closeErr *httpd.CloseError
+	if err := <-serverErr; !errors.As(err, &closeErr) || closeErr.Code != httpd.CloseGoingAway || closeErr.Text != "bye" {
+		t.Fatalf("server ReadMessage() return %v, want CloseError %d %q", err, httpd.CloseGoingAway, "bye")
+	}
+	if err = conn.WriteMessage(httpd.TextMessage, []byte("after close")); err != httpd.ErrWebSocketCloseSent {
+		t.Fatalf("WriteMessage() after close return %v, want %v", err, httpd.ErrWebSocketCloseSent)
+	}
+}
+
+func TestWebSocketCloseLongReason(t *testing.T) {
+	serverErr := make(chan error, 1)
+	wsURL := newWebSocketServer(t, nil, func(conn *httpd.WebSocketConn) {
+		_, _, err := conn.ReadMessage()
+		serverErr <- err
+	})
+	conn, err := httpd.DialWebSocket(context.Background(), wsURL, nil)
+	if err != nil {
+		t.Fatalf("DialWebSocket: %v", err)
+	}
+
+	reason := strings.Repeat("a", 122) + "你好" // rune at the limit of 123 bytes
+	conn.Close(httpd.CloseGoingAway, reason)
+	var closeErr *httpd.CloseError
+	if err := <-serverErr; !errors.As(err, &closeErr) || closeErr.Code != httpd.CloseGoingAway || closeErr.Text != reason[:122] {
+		t.Fatalf("server ReadMessage() return %v, want CloseError %d %q", err, httpd.CloseGoingAway, reason[:122])
+	}
+}
+
+func TestWebSocketMaxMessageSize(t *testing.T) {
+	serverErr := make(chan error, 1)
+	wsURL := newWebSocketServer(t, &httpd.WebSocketOptions{MaxMessageSize: 10}, func(conn *httpd.WebSocketConn) {
+		_, _, err := conn.ReadMessage()
+		serverErr <- err
+	})
+
+	conn, err := httpd.DialWebSocket(context.Background(), wsURL, &httpd.WebSocketOptions{FragmentSize: 4})
+	if err != nil {
+		t.Fatalf("DialWebSocket: %v", err)
+	}
+	conn.WriteMessage(httpd.BinaryMessage, make([]byte, 20))
+	if err := <-serverErr; err == nil {
+		t.Fatal("server ReadMessage() should fail for message too big")
+	}
+	var closeErr *httpd.CloseError
+	if _, _, err = conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != httpd.CloseMessageTooBig {
+		t.Fatalf("client ReadMessage() return %v, want CloseError %d", err, httpd.CloseMessageTooBig)
+	}
+}
+
+func TestWebSocketSubprotocol(t *testing.T) {
+	wsURL := newWebSocketServer(t, &httpd.WebSocketOptions{Subprotocols: []string{"v2", "v1"}}, echoWebSocket)
+	conn, err := httpd.DialWebSocket(context.Background(), wsURL, &httpd.WebSocketOptions{Subprotocols: []string{"v1", "v2"}})
+	if err != nil {
+		t.Fatalf("DialWebSocket: %v", err)
+	}
+	defer conn.Close(httpd.CloseNormalClosure, "")
+	if conn.Subprotocol() != "v1" {
+		t.Fatalf("Subprotocol() = %q, want %q", conn.Subprotocol(), "v1")
+	}
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		code   int
+	}{
+		{http.Header{}, 400},
+		{http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"8"}}, 426},
+		{http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}, "Sec-Websocket-Key": {"short"}}, 400},
+		{http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}, "Sec-Websocket-Key": {"dGhlIHNhbXBsZSBub25jZQ=="}, "Origin": {"http://evil.example"}}, 403},
+	}
+
+	mux := httpd.NewMux()
+	mux.Handle("/ws", http.MethodGet, func(s *httpd.Store) {
+		if _, err := s.UpgradeWebSocket(nil); err != httpd.ErrWebSocketHandshake {
+			t.Fatalf("UpgradeWebSocket() return %v, want %v", err, httpd.ErrWebSocketHandshake)
+		}
+	})
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.Header = tt.header
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Fatalf("UpgradeWebSocket(%v) reply %d, want %d", tt.header, w.Code, tt.code)
+		}
+	}
+}
+
+func TestWebSocketAcceptKey(t *testing.T) {
+	wsURL := newWebSocketServer(t, nil, echoWebSocket)
+	conn, err := net.Dial("tcp", strings.TrimPrefix(strings.TrimSuffix(wsURL, "/ws"), "ws://"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	// example from RFC 6455, section 1.3
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); resp.StatusCode != 101 || got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake response = %d %q, want %d %q", resp.StatusCode, got, 101, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	}
+}
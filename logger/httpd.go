@@ -5,12 +5,16 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"runtime/debug"
+	"slices"
 	"time"
 
 	"github.com/whoisnian/glb/ansi"
 	"github.com/whoisnian/glb/httpd"
 )
 
+// NewMiddleware returns a middleware that logs REQ_BEG and REQ_END for each request, and recovers from panic.
+// Request ID is included if httpd.RequestID middleware is registered before it,
+// and authenticated principal is included in REQ_END if auth middleware is registered after it.
 func (l *Logger) NewMiddleware() httpd.HandlerFunc {
 	return func(store *httpd.Store) {
 		start := time.Now()
@@ -19,13 +23,13 @@ func (l *Logger) NewMiddleware() httpd.HandlerFunc {
 			r := slog.NewRecord(time.Now(), LevelInfo, "", 0)
 			r.AddAttrs(slog.Attr{
 				Key: "request",
-				Value: slog.GroupValue(
+				Value: slog.GroupValue(withIdentity(store,
 					slog.String("tag", "REQ_BEG"),
 					slog.String("ip", clientIP),
 					slog.String("method", store.R.Method),
 					slog.String("path", store.R.URL.Path),
 					slog.String("query", store.R.URL.RawQuery),
-				),
+				)...),
 			})
 			l.handler.Handle(store.R.Context(), r)
 		}
@@ -34,19 +38,21 @@ func (l *Logger) NewMiddleware() httpd.HandlerFunc {
 				if store.W.Status == 0 {
 					store.W.Status = http.StatusOK
 				}
+				attrs := withIdentity(store,
+					slog.Any("tag", AnsiString{ansi.BlueFG, "REQ_END"}),
+					slog.Int("code", store.W.Status),
+					slog.Int64("dur", time.Since(start).Milliseconds()),
+					slog.String("ip", clientIP),
+					slog.String("method", store.R.Method),
+					slog.String("path", store.R.URL.Path),
+					slog.String("query", store.R.URL.RawQuery),
+				)
+				// error returned by handler created by httpd.CreateErrorHandler()
+				if err, ok := httpd.GetAs[error](store, httpd.KeyError); ok {
+					attrs = append(attrs, slog.String("err", err.Error()))
+				}
 				r := slog.NewRecord(time.Now(), LevelInfo, "", 0)
-				r.AddAttrs(slog.Attr{
-					Key: "request",
-					Value: slog.GroupValue(
-						slog.Any("tag", AnsiString{ansi.BlueFG, "REQ_END"}),
-						slog.Int("code", store.W.Status),
-						slog.Int64("dur", time.Since(start).Milliseconds()),
-						slog.String("ip", clientIP),
-						slog.String("method", store.R.Method),
-						slog.String("path", store.R.URL.Path),
-						slog.String("query", store.R.URL.RawQuery),
-					),
-				})
+				r.AddAttrs(slog.Attr{Key: "request", Value: slog.GroupValue(attrs...)})
 				l.handler.Handle(store.R.Context(), r)
 			}
 		}()
@@ -75,3 +81,14 @@ func (l *Logger) NewMiddleware() httpd.HandlerFunc {
 		store.Next()
 	}
 }
+
+// withIdentity inserts request ID after the tag attr, and appends principal name if exist.
+func withIdentity(store *httpd.Store, attrs ...slog.Attr) []slog.Attr {
+	if id := store.RequestID(); id != "" {
+		attrs = slices.Insert(attrs, 1, slog.String("id", id))
+	}
+	if p := store.Principal(); p != nil {
+		attrs = append(attrs, slog.String("user", p.Name))
+	}
+	return attrs
+}
@@ -3,8 +3,10 @@ package logger
 import (
 	"bytes"
 	"context"
+	"errors"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"testing"
 
@@ -140,3 +142,65 @@ func TestRelayRecover(t *testing.T) {
 		t.Fatalf("request log should match %s is %s", re, buf.Bytes())
 	}
 }
+
+func TestRelayRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	var l *Logger = New(NewTextHandler(&buf, Options{LevelInfo, false, false}))
+
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(httpd.RequestID(httpd.RequestIDOptions{}), l.NewMiddleware())
+	mux.Handle("/200", http.MethodGet, func(s *httpd.Store) { s.W.WriteHeader(200) })
+
+	r := httptest.NewRequest(http.MethodGet, "/200", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Request-ID", "req-1")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	reL := `time=` + reTextTime + ` level=INFO msg="" `
+	reR := `request.ip=127.0.0.1 request.method=GET request.path=/200 request.query=""\n`
+	re := `^` + reL + `request.tag=REQ_BEG request.id=req-1 ` + reR + reL + `request.tag=REQ_END request.id=req-1 request.code=200 request.dur=[0-9]+ ` + reR + `$`
+	if !regexp.MustCompile(re).Match(buf.Bytes()) {
+		t.Fatalf("request log should match %q is %q", re, buf.Bytes())
+	}
+}
+
+func TestRelayPrincipal(t *testing.T) {
+	var buf bytes.Buffer
+	var l *Logger = New(NewTextHandler(&buf, Options{LevelInfo, false, false}))
+
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(l.NewMiddleware(), httpd.BasicAuth(httpd.BasicAuthOptions{Users: map[string]string{"alice": "secret"}}))
+	mux.Handle("/200", http.MethodGet, func(s *httpd.Store) { s.W.WriteHeader(200) })
+
+	r := httptest.NewRequest(http.MethodGet, "/200", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.SetBasicAuth("alice", "secret")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	reL := `time=` + reTextTime + ` level=INFO msg="" `
+	reR := `request.ip=127.0.0.1 request.method=GET request.path=/200 request.query=""`
+	re := `^` + reL + `request.tag=REQ_BEG ` + reR + `\n` + reL + `request.tag=REQ_END request.code=200 request.dur=[0-9]+ ` + reR + ` request.user=alice\n$`
+	if !regexp.MustCompile(re).Match(buf.Bytes()) {
+		t.Fatalf("request log should match %q is %q", re, buf.Bytes())
+	}
+}
+
+func TestRelayError(t *testing.T) {
+	var buf bytes.Buffer
+	var l *Logger = New(NewTextHandler(&buf, Options{LevelInfo, false, false}))
+
+	mux := httpd.NewMux()
+	mux.HandleMiddleware(l.NewMiddleware())
+	mux.Handle("/500", http.MethodGet, httpd.CreateErrorHandler(func(s *httpd.Store) error { return errors.New("db is down") }))
+
+	r := httptest.NewRequest(http.MethodGet, "/500", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	reL := `time=` + reTextTime + ` level=INFO msg="" `
+	reR := `request.ip=127.0.0.1 request.method=GET request.path=/500 request.query=""`
+	re := `^` + reL + `request.tag=REQ_BEG ` + reR + `\n` + reL + `request.tag=REQ_END request.code=500 request.dur=[0-9]+ ` + reR + ` request.err="db is down"\n$`
+	if !regexp.MustCompile(re).Match(buf.Bytes()) {
+		t.Fatalf("request log should match %q is %q", re, buf.Bytes())
+	}
+}
@@ -1,9 +1,12 @@
 package netutil
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"net"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
@@ -127,3 +130,91 @@ func (f *IPv4Filter) Contains(ip net.IP) bool {
 	}
 	return false
 }
+
+var ErrInvalidCIDR = errors.New("invalid CIDR")
+
+// IPFilter matches both IPv4 and IPv6 addresses. IPv4 CIDRs are stored in IPv4Filter,
+// and IPv6 CIDRs are stored in a simple list as they are usually few.
+// IPv4-mapped IPv6 addresses are matched as IPv4 addresses.
+type IPFilter struct {
+	v4 *IPv4Filter
+
+	mutex sync.RWMutex
+	v6    []net.IPNet
+}
+
+func NewIPFilter() *IPFilter {
+	return &IPFilter{v4: NewIPv4Filter()}
+}
+
+func (f *IPFilter) Add(cidr *net.IPNet) error {
+	if cidr4, ok := toIPv4CIDR(cidr); ok {
+		return f.v4.Add(cidr4)
+	} else if ones, bits := cidr.Mask.Size(); bits != 8*net.IPv6len || ones > bits || len(cidr.IP) != net.IPv6len {
+		return ErrInvalidCIDR
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.v6 = append(f.v6, net.IPNet{IP: cidr.IP.Mask(cidr.Mask), Mask: cidr.Mask})
+	return nil
+}
+
+func (f *IPFilter) Remove(cidr *net.IPNet) error {
+	if cidr4, ok := toIPv4CIDR(cidr); ok {
+		return f.v4.Remove(cidr4)
+	} else if ones, bits := cidr.Mask.Size(); bits != 8*net.IPv6len || ones > bits || len(cidr.IP) != net.IPv6len {
+		return ErrInvalidCIDR
+	}
+
+	ip := cidr.IP.Mask(cidr.Mask)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.v6 = slices.DeleteFunc(f.v6, func(n net.IPNet) bool {
+		return n.IP.Equal(ip) && bytes.Equal(n.Mask, cidr.Mask)
+	})
+	return nil
+}
+
+func (f *IPFilter) Contains(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return f.v4.Contains(ip4)
+	} else if len(ip) != net.IPv6len {
+		return false
+	}
+
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	for i := range f.v6 {
+		if f.v6[i].Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// toIPv4CIDR converts IPv4 CIDR with 16-byte IP to 4-byte IP for IPv4Filter.
+func toIPv4CIDR(cidr *net.IPNet) (*net.IPNet, bool) {
+	if ones, bits := cidr.Mask.Size(); bits != 8*net.IPv4len || ones > bits {
+		return nil, false
+	} else if ip4 := cidr.IP.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: cidr.Mask}, true
+	}
+	return nil, false
+}
+
+// ParseIPOrCIDR parses s as a CIDR like "192.168.0.0/16", or a single IP like "::1" which is treated as "::1/128".
+func ParseIPOrCIDR(s string) (*net.IPNet, error) {
+	if strings.IndexByte(s, '/') >= 0 {
+		_, cidr, err := net.ParseCIDR(s)
+		return cidr, err
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: s}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len, 8*net.IPv6len)}, nil
+}
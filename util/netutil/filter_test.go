@@ -156,6 +156,53 @@ func TestIPv4FilterRace(t *testing.T) {
 	}
 }
 
+func TestIPFilter(t *testing.T) {
+	filter := NewIPFilter()
+	for _, s := range []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::1"} {
+		cidr, err := ParseIPOrCIDR(s)
+		if err != nil {
+			t.Fatalf("ParseIPOrCIDR(%q) got error %v", s, err)
+		}
+		if err = filter.Add(cidr); err != nil {
+			t.Fatalf("IPFilter.Add(%v) got error %v", cidr, err)
+		}
+	}
+	if _, err := ParseIPOrCIDR("invalid"); err == nil {
+		t.Fatalf("ParseIPOrCIDR(%q) should fail", "invalid")
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"11.1.2.3", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::1", true},
+		{"::2", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Contains(net.ParseIP(tt.ip)); got != tt.want {
+			t.Fatalf("IPFilter.Contains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	cidr, _ := ParseIPOrCIDR("2001:db8::/32")
+	filter.Remove(cidr)
+	cidr, _ = ParseIPOrCIDR("10.0.0.0/8")
+	filter.Remove(cidr)
+	if filter.Contains(net.ParseIP("2001:db8::1")) || filter.Contains(net.ParseIP("10.1.2.3")) {
+		t.Fatalf("IPFilter.Contains() = true after Remove(), want false")
+	}
+	if !filter.Contains(net.ParseIP("::1")) {
+		t.Fatalf("IPFilter.Contains(::1) = false, want true")
+	}
+}
+
 type Filter interface {
 	Add(*net.IPNet) error
 	Remove(*net.IPNet) error